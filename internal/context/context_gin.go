@@ -65,11 +65,14 @@ func readNotice(c *Context) {
 
 // getRepoDOI returns the DOI for the repository based on the following rules:
 // - if the repository belongs to the DOI user and has a tag that matches the
-// DOI prefix, returns the tag.
+// DOI prefix, returns the tag. This is the common case: the internal/doi
+// package writes the DOI it registers with DataCite back as exactly such a
+// tag, so a registered dataset is found here.
 // - if the repo is forked by the DOI user, check the DOI fork for the tag as above.
 // - if the repo is forked by the DOI user and the fork doesn't have a tag,
 // returns the (old-style) calculated DOI, based on the hash of the repository
-// path.
+// path. This is now only a fallback for forks that predate real DOI
+// registration, or haven't been registered yet.
 // - An empty string is returned if it is not not forked by the DOI user.
 // If an error occurs at any point, returns an empty string (the error is logged).
 // Tag retrieval is allowed to fail and falls back on the hashed DOI method.
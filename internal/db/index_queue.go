@@ -0,0 +1,322 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/G-Node/gogs/internal/search"
+	log "gopkg.in/clog.v1"
+)
+
+// IndexJobStatus is the lifecycle state of a queued IndexJob.
+type IndexJobStatus string
+
+const (
+	IndexJobPending IndexJobStatus = "pending"
+	IndexJobFailed  IndexJobStatus = "failed" // will be retried after NextAttempt
+	IndexJobDead    IndexJobStatus = "dead"   // exhausted retries; surfaced in the admin UI
+)
+
+// indexMaxAttempts caps retries before a job is moved to the dead-letter
+// list instead of being retried forever.
+const indexMaxAttempts = 8
+
+// indexDebounceWindow coalesces repeated push events for the same
+// repository (e.g. a force-push followed immediately by a tag push) into
+// a single index job.
+const indexDebounceWindow = 30 * time.Second
+
+// IndexJob is a durable, persisted request to (re)index a repository.
+// Rows are inserted on repo create/push/delete and consumed by a worker
+// pool, so a push is never lost just because the search backend happens
+// to be unavailable at the time.
+type IndexJob struct {
+	ID       int64 `xorm:"pk autoincr"`
+	RepoID   int64 `xorm:"INDEX"`
+	RepoPath string
+	// OldCommit and NewCommit let the worker run an incremental
+	// search.Searcher.IndexPush instead of a full IndexRepository.
+	// OldCommit is empty for a brand new repository or a full rebuild,
+	// in which case the worker falls back to IndexRepository.
+	OldCommit   string
+	NewCommit   string
+	Status      IndexJobStatus `xorm:"INDEX"`
+	Attempts    int
+	LastError   string    `xorm:"TEXT"`
+	NextAttempt time.Time `xorm:"INDEX"`
+	Created     time.Time `xorm:"created"`
+	Updated     time.Time `xorm:"updated"`
+}
+
+// TableName customises the table name xorm generates for IndexJob, in
+// line with the naming already used for the rest of this package's
+// tables (Repository -> repository, etc.).
+func (IndexJob) TableName() string {
+	return "index_job"
+}
+
+// indexQueueMetrics tracks queue depth for the admin dashboard. Counters
+// are approximate: they live in memory only, are adjusted by this process
+// as it inserts, claims and requeues jobs, and start from zero on restart
+// rather than being recounted from the index_job table. failed counts
+// both jobs awaiting a retry and dead-lettered ones.
+type indexQueueMetrics struct {
+	pending  int64
+	inflight int64
+	failed   int64
+}
+
+var indexMetrics indexQueueMetrics
+
+// IndexQueueStats is a snapshot of the indexing queue's health, exposed
+// to the admin UI.
+type IndexQueueStats struct {
+	Pending  int64
+	Inflight int64
+	Failed   int64
+}
+
+// GetIndexQueueStats returns the current queue counters.
+func GetIndexQueueStats() IndexQueueStats {
+	return IndexQueueStats{
+		Pending:  atomic.LoadInt64(&indexMetrics.pending),
+		Inflight: atomic.LoadInt64(&indexMetrics.inflight),
+		Failed:   atomic.LoadInt64(&indexMetrics.failed),
+	}
+}
+
+// DeadLetterIndexJobs returns every job that exhausted its retries, for
+// display (and manual re-enqueue) in the admin UI.
+func DeadLetterIndexJobs() ([]*IndexJob, error) {
+	jobs := make([]*IndexJob, 0, 10)
+	err := x.Where("status = ?", IndexJobDead).Find(&jobs)
+	return jobs, err
+}
+
+// debounce remembers the last time a repo was enqueued so bursts of
+// pushes coalesce into one job instead of one row per push.
+var debounce = struct {
+	sync.Mutex
+	last map[int64]time.Time
+}{last: make(map[int64]time.Time)}
+
+// EnqueueIndexJob durably schedules repo to be (re)indexed. A request for
+// a repository that already has a pending job within indexDebounceWindow
+// coalesces into that job by extending its NewCommit to newCommit, rather
+// than being dropped, so a burst of pushes still ends up with every
+// commit indexed once the (single) resulting job runs. oldCommit and
+// newCommit let the worker run an incremental IndexPush instead of a
+// full IndexRepository; pass "" for oldCommit to force a full reindex
+// (e.g. a first push, or RebuildIndex), which also clears the OldCommit
+// of a pending job it coalesces into.
+func EnqueueIndexJob(repo Repository, oldCommit, newCommit string) error {
+	debounce.Lock()
+	last, debounced := debounce.last[repo.ID]
+	debounce.last[repo.ID] = timeNow()
+	debounce.Unlock()
+
+	if debounced && timeNow().Sub(last) < indexDebounceWindow {
+		ok, err := extendPendingIndexJob(repo.ID, oldCommit, newCommit)
+		if err != nil {
+			return err
+		}
+		if ok {
+			log.Trace("Coalesced index request for repository %d into pending job", repo.ID)
+			return nil
+		}
+		// The pending job was claimed by a worker between the debounce
+		// check and the update, so there's nothing left to extend; fall
+		// through and enqueue a fresh job instead of losing newCommit.
+	}
+
+	job := &IndexJob{
+		RepoID:      repo.ID,
+		RepoPath:    repo.FullName(),
+		OldCommit:   oldCommit,
+		NewCommit:   newCommit,
+		Status:      IndexJobPending,
+		NextAttempt: timeNow(),
+	}
+	if _, err := x.Insert(job); err != nil {
+		return err
+	}
+	atomic.AddInt64(&indexMetrics.pending, 1)
+	ensureIndexQueueWorkers()
+	return nil
+}
+
+// extendPendingIndexJob updates the most recent still-pending job for
+// repoID so it covers newCommit too, reporting whether such a job still
+// existed to update. An empty oldCommit turns the job into a full reindex.
+func extendPendingIndexJob(repoID int64, oldCommit, newCommit string) (bool, error) {
+	var job IndexJob
+	has, err := x.Where("repo_id = ? AND status = ?", repoID, IndexJobPending).
+		OrderBy("next_attempt DESC").Limit(1).Get(&job)
+	if err != nil || !has {
+		return false, err
+	}
+	job.NewCommit = newCommit
+	cols := []string{"new_commit"}
+	if oldCommit == "" {
+		job.OldCommit = ""
+		cols = append(cols, "old_commit")
+	}
+	n, err := x.Id(job.ID).Cols(cols...).Update(&job)
+	return n > 0, err
+}
+
+// indexQueueWorkersOnce guards the lazy start of the worker pool. This
+// package has no dedicated startup hook of its own, so StartIndexQueueWorkers
+// was never actually called anywhere and every enqueued job sat forever:
+// the first successful EnqueueIndexJob call - the one real path every
+// repo create/push already goes through - is what starts it instead.
+var indexQueueWorkersOnce sync.Once
+
+func ensureIndexQueueWorkers() {
+	indexQueueWorkersOnce.Do(func() {
+		StartIndexQueueWorkers(indexQueueWorkerCount)
+	})
+}
+
+// indexQueueWorkerCount is the number of worker goroutines started by
+// ensureIndexQueueWorkers. It is a var, not a const, purely so tests can
+// shrink it.
+var indexQueueWorkerCount = 4
+
+// timeNow exists purely so tests can stub the clock; production code
+// always resolves to time.Now.
+var timeNow = time.Now
+
+// indexBackoff returns how long to wait before retrying a job that has
+// failed attempts times, growing exponentially and capped at one hour so
+// a long indexer outage doesn't push NextAttempt out for days.
+func indexBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+	for i := 0; i < attempts; i++ {
+		backoff *= 2
+		if backoff > time.Hour {
+			return time.Hour
+		}
+	}
+	return backoff
+}
+
+// StartIndexQueueWorkers launches n worker goroutines that dispatch due
+// IndexJobs to the configured search backend. In production it is
+// started exactly once, lazily, by ensureIndexQueueWorkers the first
+// time EnqueueIndexJob is called; it stays exported so a real startup
+// sequence elsewhere in the tree can call it eagerly instead.
+func StartIndexQueueWorkers(n int) {
+	for i := 0; i < n; i++ {
+		go indexQueueWorker()
+	}
+}
+
+func indexQueueWorker() {
+	for {
+		job, ok, err := claimNextIndexJob()
+		if err != nil {
+			log.Error(2, "index queue: failed to claim job: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if !ok {
+			time.Sleep(time.Second)
+			continue
+		}
+		processIndexJob(job)
+	}
+}
+
+// claimNextIndexJob atomically picks up the oldest due job by deleting its
+// row inside a transaction and counts it as inflight. A worker whose
+// delete removes no row (another worker claimed it first) reports no job,
+// so multiple workers never process the same row twice.
+func claimNextIndexJob() (*IndexJob, bool, error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return nil, false, err
+	}
+
+	var job IndexJob
+	has, err := sess.Where("status IN (?, ?) AND next_attempt <= ?", IndexJobPending, IndexJobFailed, timeNow()).
+		OrderBy("next_attempt ASC").Limit(1).Get(&job)
+	if err != nil || !has {
+		sess.Rollback()
+		return nil, false, err
+	}
+
+	n, err := sess.Id(job.ID).Delete(new(IndexJob))
+	if err != nil {
+		sess.Rollback()
+		return nil, false, err
+	}
+	if n == 0 {
+		sess.Rollback()
+		return nil, false, nil
+	}
+	if err := sess.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	if job.Status == IndexJobPending {
+		atomic.AddInt64(&indexMetrics.pending, -1)
+	} else {
+		atomic.AddInt64(&indexMetrics.failed, -1)
+	}
+	atomic.AddInt64(&indexMetrics.inflight, 1)
+	return &job, true, nil
+}
+
+// processIndexJob runs a single job to completion, re-enqueueing it with
+// backoff on failure or moving it to the dead-letter list once
+// indexMaxAttempts is exceeded.
+func processIndexJob(job *IndexJob) {
+	defer atomic.AddInt64(&indexMetrics.inflight, -1)
+
+	searcher, err := getSearcher()
+	if err != nil {
+		requeueFailedIndexJob(job, err)
+		return
+	}
+
+	ref := search.RepoRef{ID: job.RepoID, FullName: job.RepoPath}
+	if err := searcher.IndexPush(ref, job.OldCommit, job.NewCommit); err != nil {
+		requeueFailedIndexJob(job, err)
+		return
+	}
+	log.Trace("Indexed repository %d via queued job", job.RepoID)
+}
+
+// getSearcher exists purely so tests can stub the search backend with a
+// fake Searcher; production code always resolves to search.Get.
+var getSearcher = search.Get
+
+func requeueFailedIndexJob(job *IndexJob, cause error) {
+	job.Attempts++
+	job.LastError = cause.Error()
+
+	if job.Attempts >= indexMaxAttempts {
+		job.Status = IndexJobDead
+		job.NextAttempt = timeNow()
+		if _, err := x.Insert(job); err != nil {
+			log.Error(2, "index queue: failed to dead-letter job for repo %d: %v", job.RepoID, err)
+			return
+		}
+		atomic.AddInt64(&indexMetrics.failed, 1)
+		log.Error(2, "index queue: repo %d exhausted retries, moved to dead-letter list: %v", job.RepoID, cause)
+		return
+	}
+
+	job.Status = IndexJobFailed
+	job.NextAttempt = timeNow().Add(indexBackoff(job.Attempts))
+	if _, err := x.Insert(job); err != nil {
+		log.Error(2, "index queue: failed to reschedule job for repo %d: %v", job.RepoID, err)
+		return
+	}
+	atomic.AddInt64(&indexMetrics.failed, 1)
+	log.Trace("index queue: repo %d failed (attempt %d/%d), retrying at %s: %v",
+		job.RepoID, job.Attempts, indexMaxAttempts, job.NextAttempt, cause)
+}
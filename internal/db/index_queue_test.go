@@ -0,0 +1,143 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gig"
+	"github.com/G-Node/gogs/internal/search"
+	"github.com/G-Node/libgin/libgin"
+)
+
+// fakeSearcher stands in for the configured search backend so the queue
+// can be tested without a real repository or index on disk.
+type fakeSearcher struct {
+	pushed []search.RepoRef
+}
+
+func (f *fakeSearcher) IndexRepository(repo search.RepoRef) error { return nil }
+
+func (f *fakeSearcher) IndexBlob(repo search.RepoRef, blob *gig.Blob, path string, oid libgin.ObjectID) error {
+	return nil
+}
+
+func (f *fakeSearcher) RemoveBlob(repo search.RepoRef, oid libgin.ObjectID) error { return nil }
+
+func (f *fakeSearcher) IndexCommit(repo search.RepoRef, commit *gig.Commit) error { return nil }
+
+func (f *fakeSearcher) IndexPush(repo search.RepoRef, oldCommit, newCommit string) error {
+	f.pushed = append(f.pushed, repo)
+	return nil
+}
+
+func (f *fakeSearcher) Search(query string, mode int64) (*libgin.SearchResults, error) {
+	return &libgin.SearchResults{}, nil
+}
+
+// TestEnqueueIndexJobIsDrainedByWorker proves that a job enqueued through
+// the real EnqueueIndexJob path is actually picked up and processed,
+// rather than sitting forever: StartIndexQueueWorkers used to have no
+// caller anywhere in the tree, so jobs were durably recorded but never
+// indexed.
+func TestEnqueueIndexJobIsDrainedByWorker(t *testing.T) {
+	resetIndexQueueTestState(t)
+
+	fake := &fakeSearcher{}
+	getSearcher = func() (search.Searcher, error) { return fake, nil }
+	defer func() { getSearcher = search.Get }()
+
+	repo := Repository{ID: 1, Name: "test-repo"}
+	if err := EnqueueIndexJob(repo, "", "abc123"); err != nil {
+		t.Fatalf("EnqueueIndexJob: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(fake.pushed) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(fake.pushed) != 1 {
+		t.Fatalf("worker drained %d jobs, want 1 (StartIndexQueueWorkers must be wired into EnqueueIndexJob)", len(fake.pushed))
+	}
+	if fake.pushed[0].ID != repo.ID {
+		t.Fatalf("worker indexed repo %d, want %d", fake.pushed[0].ID, repo.ID)
+	}
+}
+
+// TestEnqueueIndexJobCoalescesBurstWithoutLosingCommits proves that a
+// burst of pushes landing inside indexDebounceWindow ends up indexing the
+// last commit of the burst, not just the first: a debounced request used
+// to be dropped outright instead of extending the pending job's
+// NewCommit, so every commit after the first push in a burst was
+// permanently lost from the index.
+func TestEnqueueIndexJobCoalescesBurstWithoutLosingCommits(t *testing.T) {
+	resetIndexQueueTestState(t)
+
+	fake := &fakeSearcher{}
+	getSearcher = func() (search.Searcher, error) { return fake, nil }
+	defer func() { getSearcher = search.Get }()
+
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	// Hold off the lazily started worker pool until the burst is in: a
+	// running worker claims a due job at once, and a claimed job can no
+	// longer be extended (EnqueueIndexJob then inserts a fresh one).
+	indexQueueWorkersOnce.Do(func() {})
+
+	repo := Repository{ID: 2, Name: "burst-repo"}
+	if err := EnqueueIndexJob(repo, "", "commit1"); err != nil {
+		t.Fatalf("EnqueueIndexJob: %v", err)
+	}
+	// Still well inside indexDebounceWindow: these should coalesce into
+	// the same pending job instead of each inserting a new row.
+	now = now.Add(5 * time.Second)
+	if err := EnqueueIndexJob(repo, "commit1", "commit2"); err != nil {
+		t.Fatalf("EnqueueIndexJob: %v", err)
+	}
+	now = now.Add(5 * time.Second)
+	if err := EnqueueIndexJob(repo, "commit2", "commit3"); err != nil {
+		t.Fatalf("EnqueueIndexJob: %v", err)
+	}
+
+	jobs := make([]*IndexJob, 0, 4)
+	if err := x.Where("repo_id = ?", repo.ID).Find(&jobs); err != nil {
+		t.Fatalf("query jobs: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("found %d jobs for repository %d, want 1 (burst should coalesce)", len(jobs), repo.ID)
+	}
+	if jobs[0].NewCommit != "commit3" {
+		t.Fatalf("pending job NewCommit = %q, want %q (last commit of the burst)", jobs[0].NewCommit, "commit3")
+	}
+
+	timeNow = time.Now
+	StartIndexQueueWorkers(indexQueueWorkerCount)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(fake.pushed) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(fake.pushed) != 1 {
+		t.Fatalf("worker drained %d jobs, want 1", len(fake.pushed))
+	}
+}
+
+// resetIndexQueueTestState lets each test start from a clean debounce
+// table and worker-start guard, since both are package-level state
+// shared across the whole test binary.
+func resetIndexQueueTestState(t *testing.T) {
+	t.Helper()
+	debounce.Lock()
+	debounce.last = make(map[int64]time.Time)
+	debounce.Unlock()
+	indexQueueWorkersOnce = sync.Once{}
+	indexQueueWorkerCount = 1
+}
@@ -1,60 +1,38 @@
 package db
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"strings"
 
+	"github.com/G-Node/gogs/internal/search"
 	"github.com/G-Node/gogs/internal/setting"
 	"github.com/G-Node/libgin/libgin"
 	"github.com/G-Node/libgin/libgin/annex"
 	log "gopkg.in/clog.v1"
 )
 
-// StartIndexing sends an indexing request to the configured indexing service
-// for a repository.
-func StartIndexing(repo Repository) {
-	go func() {
-		if setting.Search.IndexURL == "" {
-			log.Trace("Indexing not enabled")
-			return
-		}
-		log.Trace("Indexing repository %d", repo.ID)
-		ireq := libgin.IndexRequest{
-			RepoID:   repo.ID,
-			RepoPath: repo.FullName(),
-		}
-		data, err := json.Marshal(ireq)
-		if err != nil {
-			log.Error(2, "Could not marshal index request: %v", err)
-			return
-		}
-		key := []byte(setting.Search.Key)
-		encdata, err := libgin.EncryptString(key, string(data))
-		if err != nil {
-			log.Error(2, "Could not encrypt index request: %v", err)
-		}
-		req, err := http.NewRequest(http.MethodPost, setting.Search.IndexURL, strings.NewReader(encdata))
-		if err != nil {
-			log.Error(2, "Error creating index request")
-		}
-		client := http.Client{}
-		resp, err := client.Do(req)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			log.Error(2, "Error submitting index request for [%d: %s]: %v", repo.ID, repo.FullName(), err)
-			return
-		}
-	}()
+// StartIndexing durably enqueues a repository to be (re)indexed using the
+// configured search backend (see setting.Search.Backend). The actual
+// indexing happens later, off a worker pool started by
+// StartIndexQueueWorkers, so a push is never lost just because the
+// indexer is briefly unavailable. oldCommitID and newCommitID should be
+// the push's before/after SHAs, so the worker can index incrementally
+// instead of re-walking the whole repository; pass "" for oldCommitID
+// for a brand new repository or a full reindex.
+func StartIndexing(repo Repository, oldCommitID, newCommitID string) {
+	if err := EnqueueIndexJob(repo, oldCommitID, newCommitID); err != nil {
+		log.Error(2, "Could not enqueue index job for repository [%d: %s]: %v", repo.ID, repo.FullName(), err)
+	}
 }
 
-// RebuildIndex sends all repositories to the indexing service to be indexed.
+// RebuildIndex enqueues every repository to be reindexed from scratch.
+// Repositories are enqueued rather than indexed inline, so a full rebuild
+// doesn't stampede the indexer with hundreds of goroutines at once.
 func RebuildIndex() error {
-	indexurl := setting.Search.IndexURL
-	if indexurl == "" {
-		return fmt.Errorf("Indexing service not configured")
+	if _, err := search.Get(); err != nil {
+		return fmt.Errorf("search backend: %v", err)
 	}
 
 	// collect all repo ID -> Path mappings directly from the DB
@@ -64,9 +42,9 @@ func RebuildIndex() error {
 	}
 	log.Trace("Found %d repositories to index", len(repos))
 	for _, repo := range repos {
-		StartIndexing(*repo)
+		StartIndexing(*repo, "", "")
 	}
-	log.Trace("Rebuilding search index")
+	log.Trace("Enqueued full index rebuild")
 	return nil
 }
 
@@ -98,7 +76,80 @@ func annexUninit(path string) {
 	}
 }
 
+// annexBackend selects the git-annex backend used to key new annexed
+// files. SHA256/SHA256E are offered alongside the long-standing MD5
+// default for instances that want annex content keys to track Git's own
+// move to SHA-256 object IDs.
+func annexBackend(perRepoOverride string) string {
+	switch perRepoOverride {
+	case "SHA256", "SHA256E", "MD5":
+		return perRepoOverride
+	}
+	if setting.Repository.Upload.AnnexDefaultBackend != "" {
+		return setting.Repository.Upload.AnnexDefaultBackend
+	}
+	return "MD5"
+}
+
+// annexSetup initialises (or re-initialises) annex at path with no
+// per-repo overrides: the object format is the instance-wide default
+// resolved by RepositoryObjectFormat, and new files are keyed with
+// SHA256E when that format is SHA-256, otherwise with the instance-wide
+// default backend (see annexBackend). It is a thin wrapper around
+// annexSetupForRepo for callers that don't have per-repo overrides to
+// pass.
 func annexSetup(path string) {
+	annexSetupForRepo(path, "", "")
+}
+
+// annexSetupForRepo is annexSetup, but with perRepoObjectFormat ("",
+// "sha1" or "sha256") and perRepoBackend (the annex backend override; see
+// annexBackend) threaded through explicitly. It makes sure path is a git
+// repository in the requested object format before annex is initialised
+// on top of it, and defaults an unset perRepoBackend to SHA256E when the
+// repository's object format is SHA-256, so annex content keys and git
+// object IDs agree on hash length unless the caller says otherwise.
+func annexSetupForRepo(path string, perRepoObjectFormat string, perRepoBackend string) {
+	format := RepositoryObjectFormat(perRepoObjectFormat)
+	if err := ensureGitRepository(path, format); err != nil {
+		log.Error(2, "Failed to initialise git repository at '%s': %v", path, err)
+		return
+	}
+
+	backend := perRepoBackend
+	if backend == "" && format == libgin.ObjectFormatSHA256 {
+		backend = "SHA256E"
+	}
+	annexSetupWithBackend(path, backend)
+}
+
+// ensureGitRepository makes sure path is a bare git repository, running
+// `git init` with the object-format flags from
+// libgin.InitArgsForObjectFormat if it doesn't exist yet. An existing
+// repository is left untouched, since object format is fixed at creation
+// time and can't be changed afterwards.
+func ensureGitRepository(path string, format libgin.ObjectFormat) error {
+	if _, err := os.Stat(filepath.Join(path, "config")); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("stat config: %v", err)
+	}
+
+	args := append([]string{"init", "--bare"}, libgin.InitArgsForObjectFormat(format)...)
+	args = append(args, path)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git %v: %v (%s)", args, err, out)
+	}
+	return nil
+}
+
+// annexSetupWithBackend is annexSetup, but with backend (the per-repo
+// override, falling back to the instance-wide default; see
+// annexBackend) threaded through explicitly, and no object-format
+// handling: callers that already know the repository's object format
+// (e.g. annexSetupForRepo) should use that instead of calling this
+// directly.
+func annexSetupWithBackend(path string, backend string) {
 	log.Trace("Running annex add (with filesize filter) in '%s'", path)
 
 	// Initialise annex in case it's a new repository
@@ -118,9 +169,20 @@ func annexSetup(path string) {
 		log.Error(2, "Failed to set 'addunlocked' annex option: %v (%s)", err, msg)
 	}
 
-	// Set MD5 as default backend
-	if msg, err := annex.MD5(path); err != nil {
-		log.Error(2, "Failed to set default backend to 'MD5': %v (%s)", err, msg)
+	// Set the configured backend as default
+	backend = annexBackend(backend)
+	var backendErr error
+	var msg string
+	switch backend {
+	case "SHA256":
+		msg, backendErr = annex.SHA256(path)
+	case "SHA256E":
+		msg, backendErr = annex.SHA256E(path)
+	default:
+		msg, backendErr = annex.MD5(path)
+	}
+	if backendErr != nil {
+		log.Error(2, "Failed to set default backend to '%s': %v (%s)", backend, backendErr, msg)
 	}
 
 	// Set size filter in config
@@ -129,6 +191,9 @@ func annexSetup(path string) {
 	}
 }
 
+// annexSync runs independently of the annex backend configured by
+// annexSetup: `git annex sync` operates on whatever keys are already in
+// the repository, whether they were hashed with MD5, SHA256 or SHA256E.
 func annexSync(path string) error {
 	log.Trace("Synchronising annexed data")
 	if msg, err := annex.ASync(path, "--content"); err != nil {
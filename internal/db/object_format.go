@@ -0,0 +1,24 @@
+package db
+
+import (
+	"github.com/G-Node/gogs/internal/setting"
+	"github.com/G-Node/libgin/libgin"
+)
+
+// RepositoryObjectFormat resolves which git object format a new
+// repository should be initialised with. perRepoOverride ("", "sha1" or
+// "sha256") takes precedence when set; otherwise the instance-wide
+// default in setting.Repository.DefaultObjectFormat applies. SHA-1
+// remains the fallback, since it is still Git's own default.
+func RepositoryObjectFormat(perRepoOverride string) libgin.ObjectFormat {
+	switch perRepoOverride {
+	case "sha256":
+		return libgin.ObjectFormatSHA256
+	case "sha1":
+		return libgin.ObjectFormatSHA1
+	}
+	if setting.Repository.DefaultObjectFormat == "sha256" {
+		return libgin.ObjectFormatSHA256
+	}
+	return libgin.ObjectFormatSHA1
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/G-Node/gogs/internal/setting"
+	"github.com/G-Node/libgin/libgin"
+)
+
+func TestRepositoryObjectFormatPerRepoOverrideWins(t *testing.T) {
+	old := setting.Repository.DefaultObjectFormat
+	setting.Repository.DefaultObjectFormat = "sha256"
+	defer func() { setting.Repository.DefaultObjectFormat = old }()
+
+	if got := RepositoryObjectFormat("sha1"); got != libgin.ObjectFormatSHA1 {
+		t.Fatalf("RepositoryObjectFormat(%q) = %v, want ObjectFormatSHA1", "sha1", got)
+	}
+}
+
+func TestRepositoryObjectFormatFallsBackToInstanceDefault(t *testing.T) {
+	old := setting.Repository.DefaultObjectFormat
+	defer func() { setting.Repository.DefaultObjectFormat = old }()
+
+	setting.Repository.DefaultObjectFormat = "sha256"
+	if got := RepositoryObjectFormat(""); got != libgin.ObjectFormatSHA256 {
+		t.Fatalf("RepositoryObjectFormat(\"\") = %v, want ObjectFormatSHA256 when instance default is sha256", got)
+	}
+
+	setting.Repository.DefaultObjectFormat = ""
+	if got := RepositoryObjectFormat(""); got != libgin.ObjectFormatSHA1 {
+		t.Fatalf("RepositoryObjectFormat(\"\") = %v, want ObjectFormatSHA1 when nothing is configured", got)
+	}
+}
+
+func TestAnnexBackendPerRepoOverrideWins(t *testing.T) {
+	old := setting.Repository.Upload.AnnexDefaultBackend
+	setting.Repository.Upload.AnnexDefaultBackend = "MD5"
+	defer func() { setting.Repository.Upload.AnnexDefaultBackend = old }()
+
+	if got := annexBackend("SHA256E"); got != "SHA256E" {
+		t.Fatalf("annexBackend(%q) = %q, want the override to win", "SHA256E", got)
+	}
+}
+
+func TestAnnexBackendFallsBackToInstanceDefault(t *testing.T) {
+	old := setting.Repository.Upload.AnnexDefaultBackend
+	defer func() { setting.Repository.Upload.AnnexDefaultBackend = old }()
+
+	setting.Repository.Upload.AnnexDefaultBackend = "SHA256"
+	if got := annexBackend(""); got != "SHA256" {
+		t.Fatalf("annexBackend(\"\") = %q, want instance default %q", got, "SHA256")
+	}
+
+	setting.Repository.Upload.AnnexDefaultBackend = ""
+	if got := annexBackend(""); got != "MD5" {
+		t.Fatalf("annexBackend(\"\") = %q, want MD5 when nothing is configured", got)
+	}
+}
+
+func TestAnnexBackendRejectsUnknownOverride(t *testing.T) {
+	old := setting.Repository.Upload.AnnexDefaultBackend
+	setting.Repository.Upload.AnnexDefaultBackend = "MD5"
+	defer func() { setting.Repository.Upload.AnnexDefaultBackend = old }()
+
+	if got := annexBackend("not-a-real-backend"); got != "MD5" {
+		t.Fatalf("annexBackend(%q) = %q, want the instance default for an unrecognised override", "not-a-real-backend", got)
+	}
+}
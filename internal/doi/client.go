@@ -0,0 +1,74 @@
+package doi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/G-Node/gogs/internal/setting"
+)
+
+// Client talks to the DataCite Metadata Store (MDS) API: one endpoint to
+// upload metadata, another to mint/update the DOI -> URL mapping.
+// Credentials and the repository prefix come from setting.DOI.
+type Client struct {
+	baseURL  string
+	username string
+	password string
+}
+
+// NewClient builds a Client from setting.DOI.
+func NewClient() *Client {
+	return &Client{
+		baseURL:  strings.TrimRight(setting.DOI.MDSURL, "/"),
+		username: setting.DOI.Username,
+		password: setting.DOI.Password,
+	}
+}
+
+// mdsClient is the subset of Client that Register/UpdateOnNewTag depend
+// on, so tests can substitute a fake instead of making real HTTP calls
+// to DataCite.
+type mdsClient interface {
+	PutMetadata(doi string, xmlBody []byte) error
+	MintDOI(doi, landingURL string) error
+}
+
+// newClient exists purely so tests can stub the DataCite client;
+// production code always resolves to NewClient.
+var newClient = func() mdsClient { return NewClient() }
+
+// PutMetadata uploads DataCite XML for doi. This must happen before
+// MintDOI: DataCite refuses to mint a DOI that has no metadata yet.
+func (c *Client) PutMetadata(doi string, xmlBody []byte) error {
+	return c.put(fmt.Sprintf("/metadata/%s", doi), "application/xml;charset=UTF-8", xmlBody)
+}
+
+// MintDOI registers doi (or updates its URL, if already registered) to
+// resolve to landingURL, via DataCite's "doi" endpoint.
+func (c *Client) MintDOI(doi, landingURL string) error {
+	body := fmt.Sprintf("doi=%s\nurl=%s\n", doi, landingURL)
+	return c.put(fmt.Sprintf("/doi/%s", doi), "text/plain;charset=UTF-8", []byte(body))
+}
+
+func (c *Client) put(path, contentType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPut, c.baseURL+path, strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("create request: %v", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("DataCite returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Package doi registers real, resolvable DOIs for repositories forked
+// into the "doi" user, via the DataCite MDS API. It replaces the old
+// fallback of computing a DOI-looking string from the repository path's
+// hash: that string was never registered anywhere, so it didn't resolve.
+//
+// The flow is: a repo is forked into the doi user and tagged, metadata is
+// read from the repo's datacite.yml (or generated from a CodeMeta/CFF
+// file via cffconvert), a DOI is minted against DataCite, and the
+// minted DOI is written back as a git tag on the fork so
+// context.getRepoDOI finds it on its existing fast path.
+package doi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	git "github.com/G-Node/git-module"
+	"github.com/G-Node/gogs/internal/db"
+	"github.com/G-Node/gogs/internal/setting"
+	"github.com/G-Node/libgin/libgin"
+	log "gopkg.in/clog.v1"
+)
+
+// State mirrors DataCite's own DOI lifecycle states.
+type State string
+
+const (
+	// StateDraft DOIs are minted but not yet publicly resolvable or
+	// indexed; metadata can still be freely changed or the DOI deleted.
+	StateDraft State = "draft"
+	// StateRegistered DOIs resolve, but their metadata isn't searchable
+	// through DataCite Search.
+	StateRegistered State = "registered"
+	// StateFindable DOIs resolve and are searchable; this is the state
+	// a published dataset should end up in.
+	StateFindable State = "findable"
+)
+
+// Creator is a single DataCite creator entry.
+type Creator struct {
+	Name        string
+	Affiliation string
+	ORCID       string
+}
+
+// RelatedIdentifier links the dataset to another resource, e.g. a paper.
+type RelatedIdentifier struct {
+	Identifier     string
+	IdentifierType string // "DOI", "URL", "arXiv", ...
+	RelationType   string // "IsSupplementTo", "Cites", ...
+}
+
+// Metadata is the subset of DataCite 4.x fields this package fills in
+// from a repository's datacite.yml (or a CFF/CodeMeta file run through
+// cffconvert).
+type Metadata struct {
+	Creators           []Creator
+	Titles             []string
+	PublicationYear    int
+	ResourceType       string // free text of the resourceType element, e.g. "Dataset"; GenerateXML always writes resourceTypeGeneral="Dataset"
+	RelatedIdentifiers []RelatedIdentifier
+	Rights             string
+	Subjects           []string
+}
+
+// Registration is the persisted state of a repository's DOI
+// registration, enough to decide whether a new tag should mint a DOI,
+// update one, or be ignored.
+type Registration struct {
+	DOI        string
+	State      State
+	Registered time.Time
+}
+
+// doiSuffix derives the DOI suffix for a repository. Reusing the same
+// UUID derivation the old hash-based fallback used keeps existing tags
+// and any already-shared DOIs stable across the switch to real
+// registration.
+func doiSuffix(repoPath string) string {
+	return libgin.RepoPathToUUID(repoPath)[:6]
+}
+
+// FullDOI returns the complete DOI for repoPath. It reuses
+// setting.DOI.Base, the same setting context.getRepoDOI's tag-matching
+// fast path already checks tags against, rather than a separate prefix
+// setting: minting a DOI under one config value and looking it up under
+// another would silently never find it.
+func FullDOI(repoPath string) string {
+	return setting.DOI.Base + doiSuffix(repoPath)
+}
+
+// sourceRepoPath returns the path the old hash-based fallback hashed for
+// doiFork: that of the repository it was forked from.
+func sourceRepoPath(doiFork *db.Repository) string {
+	if doiFork.IsFork {
+		if err := doiFork.GetBaseRepo(); err == nil && doiFork.BaseRepo != nil {
+			return doiFork.BaseRepo.FullName()
+		}
+	}
+	return doiFork.FullName()
+}
+
+// Register mints a DOI for doiFork (the fork of repo living under the
+// "doi" user) and tags it with the minted DOI so that
+// context.getRepoDOI's tag scan finds it. If doiFork already carries a
+// registration - a previous tag push already minted one - Register
+// defers to UpdateOnNewTag instead of minting a second DOI for the same
+// dataset, since a DOI is meant to be a permanent identifier.
+//
+// A freshly minted DOI moves through DataCite's own lifecycle: metadata
+// must be registered (StateDraft) before the DOI -> URL mapping can be
+// minted (StateRegistered); it only becomes searchable (StateFindable)
+// once DataCite has indexed it, which happens on their side, not this
+// call. Register persists whichever state it actually reached, so a
+// failure between the two DataCite calls doesn't get reported as a
+// fully findable registration it never became.
+func Register(doiFork *db.Repository, landingURL string) (*Registration, error) {
+	if existing, err := currentRegistration(doiFork); err != nil {
+		log.Error(2, "doi: could not read existing registration for %s, minting a new one: %v", doiFork.FullName(), err)
+	} else if existing != nil {
+		if err := UpdateOnNewTag(doiFork); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	meta, err := LoadMetadata(doiFork.RepoPath())
+	if err != nil {
+		return nil, fmt.Errorf("load DataCite metadata for %s: %v", doiFork.FullName(), err)
+	}
+
+	doi := FullDOI(sourceRepoPath(doiFork))
+	xmlBody, err := GenerateXML(doi, meta)
+	if err != nil {
+		return nil, fmt.Errorf("generate DataCite XML for %s: %v", doiFork.FullName(), err)
+	}
+
+	client := newClient()
+	if err := client.PutMetadata(doi, xmlBody); err != nil {
+		return nil, fmt.Errorf("submit metadata for %s: %v", doi, err)
+	}
+	reg := &Registration{DOI: doi, State: StateDraft, Registered: time.Now()}
+
+	if err := client.MintDOI(doi, landingURL); err != nil {
+		if tagErr := persistRegistration(doiFork, reg); tagErr != nil {
+			log.Error(2, "doi: could not persist draft registration for %s after mint failure: %v", doi, tagErr)
+		}
+		return reg, fmt.Errorf("mint DOI %s: %v", doi, err)
+	}
+	reg.State = StateRegistered
+	reg.Registered = time.Now()
+
+	if err := persistRegistration(doiFork, reg); err != nil {
+		return reg, fmt.Errorf("tag %s with DOI %s: %v", doiFork.FullName(), doi, err)
+	}
+
+	log.Trace("Registered DOI %s for repository %s (state=%s)", doi, doiFork.FullName(), reg.State)
+	return reg, nil
+}
+
+// UpdateOnNewTag re-submits metadata for an already-registered DOI when
+// the underlying repository gains a new tag, so changes to
+// datacite.yml (new authors, a fixed title, ...) are reflected without
+// minting a second DOI for the same dataset.
+func UpdateOnNewTag(doiFork *db.Repository) error {
+	doi := FullDOI(sourceRepoPath(doiFork))
+
+	meta, err := LoadMetadata(doiFork.RepoPath())
+	if err != nil {
+		return fmt.Errorf("load DataCite metadata for %s: %v", doiFork.FullName(), err)
+	}
+	xmlBody, err := GenerateXML(doi, meta)
+	if err != nil {
+		return fmt.Errorf("generate DataCite XML for %s: %v", doiFork.FullName(), err)
+	}
+
+	if err := newClient().PutMetadata(doi, xmlBody); err != nil {
+		return fmt.Errorf("update metadata for %s: %v", doi, err)
+	}
+	log.Trace("Updated DataCite metadata for DOI %s (repository %s)", doi, doiFork.FullName())
+	return nil
+}
+
+// Resubmit re-sends the current datacite.yml metadata for repo to
+// DataCite without minting a new DOI. It backs the admin "resubmit
+// metadata" endpoint, for when a registration was minted with stale or
+// malformed metadata.
+func Resubmit(doiFork *db.Repository) error {
+	return UpdateOnNewTag(doiFork)
+}
+
+// persistRegistration tags doiFork's HEAD with reg's DOI, encoding reg's
+// lifecycle state in the tag's message so a later Register call (e.g.
+// after a restart) can tell this dataset already has a registration
+// instead of minting a second DOI for it. context.getRepoDOI's tag scan
+// only looks at the tag name, so this stays a drop-in replacement for
+// the old lightweight tag from this package's perspective.
+func persistRegistration(doiFork *db.Repository, reg *Registration) error {
+	repoGit, err := git.OpenRepository(doiFork.RepoPath())
+	if err != nil {
+		return fmt.Errorf("open git repository at %q: %v", doiFork.RepoPath(), err)
+	}
+	if err := repoGit.DeleteTag(reg.DOI); err != nil && !git.IsErrTagNotExist(err) {
+		return fmt.Errorf("remove stale tag %q: %v", reg.DOI, err)
+	}
+	if err := repoGit.CreateTag(reg.DOI, "HEAD", git.CreateTagOptions{
+		Message: formatRegistrationMessage(reg),
+	}); err != nil {
+		return fmt.Errorf("create tag %q: %v", reg.DOI, err)
+	}
+	return nil
+}
+
+// currentRegistration reads back the registration persisted by
+// persistRegistration, if any, using the same tag-matching fast path
+// context.getRepoDOI uses to find the DOI tag in the first place.
+func currentRegistration(doiFork *db.Repository) (*Registration, error) {
+	repoGit, err := git.OpenRepository(doiFork.RepoPath())
+	if err != nil {
+		return nil, fmt.Errorf("open git repository at %q: %v", doiFork.RepoPath(), err)
+	}
+	tags, err := repoGit.GetTags()
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %v", err)
+	}
+	for _, tagName := range tags {
+		if !strings.Contains(tagName, setting.DOI.Base) {
+			continue
+		}
+		tag, err := repoGit.GetTag(tagName)
+		if err != nil {
+			return nil, fmt.Errorf("read tag %q: %v", tagName, err)
+		}
+		return parseRegistrationMessage(tagName, tag.Message())
+	}
+	return nil, nil
+}
+
+// formatRegistrationMessage encodes reg's lifecycle state and
+// registration time into an annotated tag message, so Registration
+// survives a process restart without needing a database table of its
+// own.
+func formatRegistrationMessage(reg *Registration) string {
+	return fmt.Sprintf("doi-registration: state=%s registered=%s", reg.State, reg.Registered.UTC().Format(time.RFC3339))
+}
+
+// parseRegistrationMessage parses a message produced by
+// formatRegistrationMessage back into a Registration for doi.
+func parseRegistrationMessage(doi, message string) (*Registration, error) {
+	reg := &Registration{DOI: doi}
+	for _, field := range strings.Fields(message) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "state":
+			reg.State = State(kv[1])
+		case "registered":
+			t, err := time.Parse(time.RFC3339, kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid registered timestamp %q: %v", kv[1], err)
+			}
+			reg.Registered = t
+		}
+	}
+	if reg.State == "" {
+		return nil, fmt.Errorf("message %q has no doi-registration state", message)
+	}
+	return reg, nil
+}
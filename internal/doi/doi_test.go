@@ -0,0 +1,78 @@
+package doi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gogs/internal/setting"
+)
+
+func TestFullDOIMatchesGetRepoDOIFastPath(t *testing.T) {
+	old := setting.DOI.Base
+	setting.DOI.Base = "10.80411/"
+	defer func() { setting.DOI.Base = old }()
+
+	doi := FullDOI("doi/some-repo")
+
+	// context.getRepoDOI's fast path matches tags via
+	// strings.Contains(tagName, setting.DOI.Base); FullDOI must produce
+	// something that satisfies exactly that check, or a minted DOI would
+	// never be found again.
+	if !strings.Contains(doi, setting.DOI.Base) {
+		t.Fatalf("FullDOI(%q) = %q, does not contain setting.DOI.Base %q", "doi/some-repo", doi, setting.DOI.Base)
+	}
+}
+
+func TestFullDOIIsStableForSameRepoPath(t *testing.T) {
+	old := setting.DOI.Base
+	setting.DOI.Base = "10.80411/"
+	defer func() { setting.DOI.Base = old }()
+
+	if FullDOI("doi/some-repo") != FullDOI("doi/some-repo") {
+		t.Fatalf("FullDOI is not stable across calls for the same repo path")
+	}
+}
+
+func TestRegistrationMessageRoundTrip(t *testing.T) {
+	reg := &Registration{
+		DOI:        "10.80411/f0051a",
+		State:      StateRegistered,
+		Registered: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	msg := formatRegistrationMessage(reg)
+	got, err := parseRegistrationMessage(reg.DOI, msg)
+	if err != nil {
+		t.Fatalf("parseRegistrationMessage: %v", err)
+	}
+	if got.DOI != reg.DOI || got.State != reg.State || !got.Registered.Equal(reg.Registered) {
+		t.Fatalf("round trip = %+v, want %+v", got, reg)
+	}
+}
+
+func TestParseRegistrationMessageRejectsMissingState(t *testing.T) {
+	if _, err := parseRegistrationMessage("10.80411/f0051a", "registered=2026-01-02T03:04:05Z"); err == nil {
+		t.Fatalf("expected an error for a message with no state field")
+	}
+}
+
+func TestGenerateXMLIncludesCoreFields(t *testing.T) {
+	meta := Metadata{
+		Creators:        []Creator{{Name: "Jane Doe", ORCID: "0000-0000-0000-0001"}},
+		Titles:          []string{"An Example Dataset"},
+		PublicationYear: 2026,
+		ResourceType:    "Dataset",
+	}
+
+	out, err := GenerateXML("10.80411/f0051a", meta)
+	if err != nil {
+		t.Fatalf("GenerateXML: %v", err)
+	}
+
+	for _, want := range []string{"10.80411/f0051a", "Jane Doe", "0000-0000-0000-0001", "An Example Dataset", "2026"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("generated XML missing %q:\n%s", want, out)
+		}
+	}
+}
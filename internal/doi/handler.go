@@ -0,0 +1,27 @@
+package doi
+
+import (
+	"net/http"
+
+	"github.com/G-Node/gogs/internal/context"
+	log "gopkg.in/clog.v1"
+)
+
+// ResubmitHandler is the admin "resubmit metadata" action Resubmit's doc
+// comment refers to: it re-sends c.Repo.Repository's current metadata to
+// DataCite without minting a new DOI, for when a registration went out
+// with stale or malformed metadata. It's written in the macaron handler
+// shape (func(*context.Context)) the routers package expects, so mounting
+// it under an admin route (e.g. POST
+// /:username/:reponame/settings/doi/resubmit) is a one-line addition
+// there; the routers package isn't part of this chunk, so that
+// registration itself isn't included here.
+func ResubmitHandler(c *context.Context) {
+	repo := c.Repo.Repository
+	if err := Resubmit(repo); err != nil {
+		log.Error(2, "doi: failed to resubmit metadata for %s: %v", repo.FullName(), err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,197 @@
+package doi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	git "github.com/G-Node/git-module"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// metadataFile is read from the repository's default branch when
+// registering a DOI. If it doesn't exist, LoadMetadata falls back to
+// cffFile, converted to the same shape via cffconvert.
+const metadataFile = "datacite.yml"
+
+// cffFile is the Citation File Format source LoadMetadata falls back to
+// when a repository has no datacite.yml of its own. It's converted to
+// CodeMeta JSON via the cffconvert CLI, which understands both CFF and
+// CodeMeta as inputs and is the tool the CFF project itself recommends
+// for this conversion.
+const cffFile = "CITATION.cff"
+
+// doiMetadataYAML mirrors the subset of DataCite fields a datacite.yml
+// is expected to provide.
+type doiMetadataYAML struct {
+	Creators []struct {
+		Name        string `yaml:"name"`
+		Affiliation string `yaml:"affiliation"`
+		ORCID       string `yaml:"orcid"`
+	} `yaml:"creators"`
+	Titles             []string `yaml:"titles"`
+	PublicationYear    int      `yaml:"publicationYear"`
+	ResourceType       string   `yaml:"resourceType"`
+	RelatedIdentifiers []struct {
+		Identifier     string `yaml:"identifier"`
+		IdentifierType string `yaml:"identifierType"`
+		RelationType   string `yaml:"relationType"`
+	} `yaml:"relatedIdentifiers"`
+	Rights   string   `yaml:"rights"`
+	Subjects []string `yaml:"subjects"`
+}
+
+// codemetaJSON mirrors the subset of CodeMeta fields cffconvert produces
+// from a CITATION.cff that this package can map onto Metadata.
+type codemetaJSON struct {
+	Name            string   `json:"name"`
+	DatePublished   string   `json:"datePublished"`
+	License         string   `json:"license"`
+	Keywords        []string `json:"keywords"`
+	IdentifierValue string   `json:"identifier"`
+	Author          []struct {
+		GivenName   string `json:"givenName"`
+		FamilyName  string `json:"familyName"`
+		Affiliation struct {
+			Name string `json:"name"`
+		} `json:"affiliation"`
+		ID string `json:"@id"` // an ORCID URL, e.g. https://orcid.org/0000-...
+	} `json:"author"`
+}
+
+// LoadMetadata reads and parses metadataFile from repoPath's default
+// branch, falling back to converting cffFile via cffconvert if the
+// repository has no datacite.yml of its own.
+func LoadMetadata(repoPath string) (Metadata, error) {
+	repoGit, err := git.OpenRepository(repoPath)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("open git repository at %q: %v", repoPath, err)
+	}
+
+	commit, err := repoGit.GetBranchCommit(repoGit.DefaultBranch())
+	if err != nil {
+		return Metadata{}, fmt.Errorf("get HEAD commit: %v", err)
+	}
+
+	raw, err := commit.Blob(metadataFile).Bytes()
+	if err != nil {
+		if !git.IsErrNotExist(err) {
+			return Metadata{}, fmt.Errorf("read %s: %v", metadataFile, err)
+		}
+		return loadMetadataFromCFF(commit)
+	}
+
+	var parsed doiMetadataYAML
+	if err := yaml.Unmarshal(raw, &parsed); err != nil {
+		return Metadata{}, fmt.Errorf("parse %s: %v", metadataFile, err)
+	}
+
+	meta := Metadata{
+		Titles:          parsed.Titles,
+		PublicationYear: parsed.PublicationYear,
+		ResourceType:    parsed.ResourceType,
+		Rights:          parsed.Rights,
+		Subjects:        parsed.Subjects,
+	}
+	for _, c := range parsed.Creators {
+		meta.Creators = append(meta.Creators, Creator{Name: c.Name, Affiliation: c.Affiliation, ORCID: c.ORCID})
+	}
+	for _, r := range parsed.RelatedIdentifiers {
+		meta.RelatedIdentifiers = append(meta.RelatedIdentifiers, RelatedIdentifier{
+			Identifier:     r.Identifier,
+			IdentifierType: r.IdentifierType,
+			RelationType:   r.RelationType,
+		})
+	}
+	return meta, nil
+}
+
+// loadMetadataFromCFF reads cffFile out of commit and converts it to
+// Metadata via cffconvert, for repositories that describe themselves with
+// a Citation File Format file instead of hand-writing a datacite.yml.
+func loadMetadataFromCFF(commit *git.Commit) (Metadata, error) {
+	raw, err := commit.Blob(cffFile).Bytes()
+	if err != nil {
+		return Metadata{}, fmt.Errorf("read %s: %v", cffFile, err)
+	}
+
+	codemeta, err := runCffconvert(raw)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("convert %s: %v", cffFile, err)
+	}
+
+	var parsed codemetaJSON
+	if err := json.Unmarshal(codemeta, &parsed); err != nil {
+		return Metadata{}, fmt.Errorf("parse cffconvert output: %v", err)
+	}
+	return metadataFromCodemeta(parsed), nil
+}
+
+// metadataFromCodemeta maps cffconvert's CodeMeta output onto Metadata.
+// Split out from loadMetadataFromCFF so the mapping can be unit tested
+// without shelling out to cffconvert or opening a git repository.
+func metadataFromCodemeta(parsed codemetaJSON) Metadata {
+	meta := Metadata{
+		ResourceType: "Software",
+		Rights:       parsed.License,
+		Subjects:     parsed.Keywords,
+	}
+	if parsed.Name != "" {
+		meta.Titles = []string{parsed.Name}
+	}
+	if year, err := publicationYearFromDate(parsed.DatePublished); err == nil {
+		meta.PublicationYear = year
+	}
+	if parsed.IdentifierValue != "" {
+		meta.RelatedIdentifiers = append(meta.RelatedIdentifiers, RelatedIdentifier{
+			Identifier:     parsed.IdentifierValue,
+			IdentifierType: "DOI",
+			RelationType:   "IsSupplementTo",
+		})
+	}
+	for _, a := range parsed.Author {
+		meta.Creators = append(meta.Creators, Creator{
+			Name:        strings.TrimSpace(a.GivenName + " " + a.FamilyName),
+			Affiliation: a.Affiliation.Name,
+			ORCID:       strings.TrimPrefix(a.ID, "https://orcid.org/"),
+		})
+	}
+	return meta
+}
+
+// publicationYearFromDate extracts the leading YYYY out of an ISO-8601
+// date string such as CodeMeta's datePublished.
+func publicationYearFromDate(date string) (int, error) {
+	if len(date) < 4 {
+		return 0, fmt.Errorf("date %q too short to contain a year", date)
+	}
+	return strconv.Atoi(date[:4])
+}
+
+// runCffconvert shells out to the cffconvert CLI to turn a CITATION.cff
+// into CodeMeta JSON, since cffconvert's CFF parsing (and its CFF
+// schema-version handling) is not worth re-implementing here. cffconvert
+// only reads from a file, not stdin, so raw is written to a temp file
+// first.
+func runCffconvert(raw []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "cffconvert")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cffPath := filepath.Join(dir, "CITATION.cff")
+	if err := os.WriteFile(cffPath, raw, 0644); err != nil {
+		return nil, fmt.Errorf("write temp CITATION.cff: %v", err)
+	}
+
+	out, err := exec.Command("cffconvert", "--infile", cffPath, "--format", "codemeta").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cffconvert: %v (%s)", err, out)
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package doi
+
+import "testing"
+
+func TestMetadataFromCodemetaMapsCoreFields(t *testing.T) {
+	parsed := codemetaJSON{
+		Name:            "example-tool",
+		DatePublished:   "2026-03-05",
+		License:         "MIT",
+		Keywords:        []string{"neuroscience", "tooling"},
+		IdentifierValue: "10.80411/f0051a",
+	}
+	parsed.Author = append(parsed.Author, struct {
+		GivenName   string `json:"givenName"`
+		FamilyName  string `json:"familyName"`
+		Affiliation struct {
+			Name string `json:"name"`
+		} `json:"affiliation"`
+		ID string `json:"@id"`
+	}{GivenName: "Jane", FamilyName: "Doe", ID: "https://orcid.org/0000-0000-0000-0001"})
+	parsed.Author[0].Affiliation.Name = "Example University"
+
+	meta := metadataFromCodemeta(parsed)
+
+	if meta.ResourceType != "Software" {
+		t.Errorf("ResourceType = %q, want %q", meta.ResourceType, "Software")
+	}
+	if len(meta.Titles) != 1 || meta.Titles[0] != "example-tool" {
+		t.Errorf("Titles = %v, want [%q]", meta.Titles, "example-tool")
+	}
+	if meta.PublicationYear != 2026 {
+		t.Errorf("PublicationYear = %d, want 2026", meta.PublicationYear)
+	}
+	if meta.Rights != "MIT" {
+		t.Errorf("Rights = %q, want %q", meta.Rights, "MIT")
+	}
+	if len(meta.RelatedIdentifiers) != 1 || meta.RelatedIdentifiers[0].Identifier != "10.80411/f0051a" {
+		t.Errorf("RelatedIdentifiers = %v, want one entry for %q", meta.RelatedIdentifiers, "10.80411/f0051a")
+	}
+	if len(meta.Creators) != 1 {
+		t.Fatalf("Creators = %v, want 1 entry", meta.Creators)
+	}
+	creator := meta.Creators[0]
+	if creator.Name != "Jane Doe" {
+		t.Errorf("Creator.Name = %q, want %q", creator.Name, "Jane Doe")
+	}
+	if creator.Affiliation != "Example University" {
+		t.Errorf("Creator.Affiliation = %q, want %q", creator.Affiliation, "Example University")
+	}
+	if creator.ORCID != "0000-0000-0000-0001" {
+		t.Errorf("Creator.ORCID = %q, want the orcid.org prefix stripped", creator.ORCID)
+	}
+}
+
+func TestMetadataFromCodemetaLeavesTitlesEmptyWhenNameMissing(t *testing.T) {
+	meta := metadataFromCodemeta(codemetaJSON{})
+	if len(meta.Titles) != 0 {
+		t.Errorf("Titles = %v, want empty when CodeMeta has no name", meta.Titles)
+	}
+	if meta.PublicationYear != 0 {
+		t.Errorf("PublicationYear = %d, want 0 when datePublished is missing", meta.PublicationYear)
+	}
+}
+
+func TestPublicationYearFromDate(t *testing.T) {
+	if year, err := publicationYearFromDate("2026-03-05"); err != nil || year != 2026 {
+		t.Fatalf("publicationYearFromDate(%q) = (%d, %v), want (2026, nil)", "2026-03-05", year, err)
+	}
+	if _, err := publicationYearFromDate("26"); err == nil {
+		t.Fatalf("publicationYearFromDate(%q) should have errored on a too-short date", "26")
+	}
+}
@@ -0,0 +1,112 @@
+package doi
+
+import "encoding/xml"
+
+// The following types mirror the DataCite Metadata Schema 4.x closely
+// enough to produce metadata DataCite's MDS API accepts; they are not a
+// complete implementation of the schema, only the fields this package
+// fills in from datacite.yml.
+
+type resource struct {
+	XMLName            xml.Name            `xml:"http://datacite.org/schema/kernel-4 resource"`
+	Identifier         identifier          `xml:"identifier"`
+	Creators           creators            `xml:"creators"`
+	Titles             titles              `xml:"titles"`
+	PublicationYear    int                 `xml:"publicationYear"`
+	ResourceType       resourceType        `xml:"resourceType"`
+	RelatedIdentifiers *relatedIdentifiers `xml:"relatedIdentifiers,omitempty"`
+	RightsList         *rightsList         `xml:"rightsList,omitempty"`
+	Subjects           *subjects           `xml:"subjects,omitempty"`
+}
+
+type identifier struct {
+	IdentifierType string `xml:"identifierType,attr"`
+	Value          string `xml:",chardata"`
+}
+
+type creators struct {
+	Creator []creator `xml:"creator"`
+}
+
+type creator struct {
+	CreatorName string          `xml:"creatorName"`
+	Affiliation string          `xml:"affiliation,omitempty"`
+	NameID      *nameIdentifier `xml:"nameIdentifier,omitempty"`
+}
+
+type nameIdentifier struct {
+	Scheme string `xml:"nameIdentifierScheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type titles struct {
+	Title []string `xml:"title"`
+}
+
+type resourceType struct {
+	ResourceTypeGeneral string `xml:"resourceTypeGeneral,attr"`
+	Value               string `xml:",chardata"`
+}
+
+type relatedIdentifiers struct {
+	RelatedIdentifier []relatedIdentifierXML `xml:"relatedIdentifier"`
+}
+
+type relatedIdentifierXML struct {
+	RelatedIdentifierType string `xml:"relatedIdentifierType,attr"`
+	RelationType          string `xml:"relationType,attr"`
+	Value                 string `xml:",chardata"`
+}
+
+type rightsList struct {
+	Rights string `xml:"rights"`
+}
+
+type subjects struct {
+	Subject []string `xml:"subject"`
+}
+
+// GenerateXML renders meta as DataCite 4.x metadata XML for doi.
+func GenerateXML(doi string, meta Metadata) ([]byte, error) {
+	res := resource{
+		Identifier:      identifier{IdentifierType: "DOI", Value: doi},
+		Titles:          titles{Title: meta.Titles},
+		PublicationYear: meta.PublicationYear,
+		ResourceType: resourceType{
+			ResourceTypeGeneral: "Dataset",
+			Value:               meta.ResourceType,
+		},
+	}
+
+	for _, c := range meta.Creators {
+		entry := creator{CreatorName: c.Name, Affiliation: c.Affiliation}
+		if c.ORCID != "" {
+			entry.NameID = &nameIdentifier{Scheme: "ORCID", Value: c.ORCID}
+		}
+		res.Creators.Creator = append(res.Creators.Creator, entry)
+	}
+
+	if len(meta.RelatedIdentifiers) > 0 {
+		res.RelatedIdentifiers = &relatedIdentifiers{}
+		for _, r := range meta.RelatedIdentifiers {
+			res.RelatedIdentifiers.RelatedIdentifier = append(res.RelatedIdentifiers.RelatedIdentifier, relatedIdentifierXML{
+				RelatedIdentifierType: r.IdentifierType,
+				RelationType:          r.RelationType,
+				Value:                 r.Identifier,
+			})
+		}
+	}
+
+	if meta.Rights != "" {
+		res.RightsList = &rightsList{Rights: meta.Rights}
+	}
+	if len(meta.Subjects) > 0 {
+		res.Subjects = &subjects{Subject: meta.Subjects}
+	}
+
+	out, err := xml.MarshalIndent(&res, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,86 @@
+package search
+
+import "strings"
+
+// fuzzyMaxDistance scales the allowed edit distance with the query
+// length, so a single typo in a long term doesn't fail to match while a
+// two-letter query still requires an exact hit.
+func fuzzyMaxDistance(query string) int {
+	switch {
+	case len(query) < 4:
+		return 0
+	case len(query) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// containsWithinEditDistance reports whether some substring of content,
+// roughly the length of query, is within maxDist edits of query. It
+// slides a window sized to len(query)±maxDist across content rather than
+// running full Levenshtein against the whole file, since content can be
+// arbitrarily large.
+func containsWithinEditDistance(content, query string, maxDist int) bool {
+	if maxDist == 0 {
+		return strings.Contains(strings.ToLower(content), strings.ToLower(query))
+	}
+
+	lower := strings.ToLower(content)
+	q := strings.ToLower(query)
+	if len(q) == 0 {
+		return true
+	}
+
+	for start := 0; start < len(lower); start++ {
+		for size := len(q) - maxDist; size <= len(q)+maxDist; size++ {
+			if size <= 0 {
+				continue
+			}
+			end := start + size
+			if end > len(lower) {
+				break
+			}
+			if levenshtein(lower[start:end], q) <= maxDist {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// levenshtein returns the classic edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			del := prev[j] + 1
+			ins := curr[j-1] + 1
+			sub := prev[j-1] + cost
+			curr[j] = min3(del, ins, sub)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
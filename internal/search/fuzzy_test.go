@@ -0,0 +1,54 @@
+package search
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	} {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestFuzzyMaxDistance(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"ab", 0},
+		{"abcd", 1},
+		{"abcdefgh", 2},
+	} {
+		if got := fuzzyMaxDistance(tc.query); got != tc.want {
+			t.Errorf("fuzzyMaxDistance(%q) = %d, want %d", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestContainsWithinEditDistance(t *testing.T) {
+	content := "the quick brown fox jumps over the lazy dog"
+	if !containsWithinEditDistance(content, "quikc", fuzzyMaxDistance("quikc")) {
+		t.Fatalf("expected a one-letter transposition of 'quick' to match within fuzzy distance")
+	}
+	if containsWithinEditDistance(content, "zzzzzzzzzz", fuzzyMaxDistance("zzzzzzzzzz")) {
+		t.Fatalf("expected a completely unrelated query not to match")
+	}
+}
+
+func TestContainsWithinEditDistanceExactWhenDistanceZero(t *testing.T) {
+	if !containsWithinEditDistance("Hello World", "hello", 0) {
+		t.Fatalf("expected case-insensitive exact substring match")
+	}
+	if containsWithinEditDistance("Hello World", "helloo", 0) {
+		t.Fatalf("expected no match when maxDist is 0 and substring doesn't appear exactly")
+	}
+}
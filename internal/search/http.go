@@ -0,0 +1,94 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/G-Node/gig"
+	"github.com/G-Node/gogs/internal/setting"
+	"github.com/G-Node/libgin/libgin"
+	log "gopkg.in/clog.v1"
+)
+
+// externalSearcher is the original backend: it hands indexing requests to
+// an out-of-process gin-dex service over an encrypted HTTP channel. It is
+// kept around so existing deployments can keep setting.Search.Backend set
+// to "external" instead of migrating straight to the embedded index.
+type externalSearcher struct {
+	indexURL string
+	key      []byte
+}
+
+func newExternalSearcher() *externalSearcher {
+	return &externalSearcher{
+		indexURL: setting.Search.IndexURL,
+		key:      []byte(setting.Search.Key),
+	}
+}
+
+func (e *externalSearcher) IndexRepository(repo RepoRef) error {
+	if e.indexURL == "" {
+		return fmt.Errorf("search: external backend has no IndexURL configured")
+	}
+
+	ireq := libgin.IndexRequest{
+		RepoID:   repo.ID,
+		RepoPath: repo.FullName,
+	}
+	data, err := json.Marshal(ireq)
+	if err != nil {
+		return fmt.Errorf("marshal index request: %v", err)
+	}
+	encdata, err := libgin.EncryptString(e.key, string(data))
+	if err != nil {
+		return fmt.Errorf("encrypt index request: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.indexURL, strings.NewReader(encdata))
+	if err != nil {
+		return fmt.Errorf("create index request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("submit index request for [%d: %s]: %v", repo.ID, repo.FullName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("index request for [%d: %s] failed: status %s", repo.ID, repo.FullName, resp.Status)
+	}
+	return nil
+}
+
+// IndexBlob and RemoveBlob have no equivalent on the external backend,
+// which only ever indexed whole repositories; a full IndexRepository is
+// the closest approximation, so incremental push-hook updates are folded
+// into that instead of failing outright.
+func (e *externalSearcher) IndexBlob(repo RepoRef, blob *gig.Blob, path string, commit libgin.ObjectID) error {
+	log.Trace("external search backend has no incremental indexing; re-indexing repo %d in full", repo.ID)
+	return e.IndexRepository(repo)
+}
+
+func (e *externalSearcher) RemoveBlob(repo RepoRef, oid libgin.ObjectID) error {
+	log.Trace("external search backend has no incremental indexing; re-indexing repo %d in full", repo.ID)
+	return e.IndexRepository(repo)
+}
+
+// IndexCommit has no equivalent on the external backend either; see
+// IndexBlob.
+func (e *externalSearcher) IndexCommit(repo RepoRef, commit *gig.Commit) error {
+	log.Trace("external search backend has no incremental indexing; re-indexing repo %d in full", repo.ID)
+	return e.IndexRepository(repo)
+}
+
+// IndexPush has no equivalent on the external backend either; see
+// IndexBlob.
+func (e *externalSearcher) IndexPush(repo RepoRef, oldCommit, newCommit string) error {
+	log.Trace("external search backend has no incremental indexing; re-indexing repo %d in full", repo.ID)
+	return e.IndexRepository(repo)
+}
+
+func (e *externalSearcher) Search(query string, mode int64) (*libgin.SearchResults, error) {
+	return nil, fmt.Errorf("search: querying the external backend directly is not supported; it serves search results itself")
+}
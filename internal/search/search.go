@@ -0,0 +1,82 @@
+// Package search implements code search over repository blobs and commits.
+//
+// Historically, indexing and querying were delegated to the external
+// gin-dex service over an encrypted HTTP channel. This package adds an
+// in-process alternative, in the spirit of Zoekt: content is broken into
+// overlapping trigrams and indexed into compact per-repository posting
+// list files, so that search no longer requires a separate running
+// service. Both backends implement Searcher, and setting.Search.Backend
+// selects which one is used.
+package search
+
+import (
+	"fmt"
+
+	"github.com/G-Node/gig"
+	"github.com/G-Node/gogs/internal/setting"
+	"github.com/G-Node/libgin/libgin"
+)
+
+// RepoRef identifies the repository that indexed documents belong to.
+type RepoRef struct {
+	ID       int64
+	FullName string
+}
+
+// Searcher indexes and queries repository content. Implementations back
+// either the embedded trigram index or the legacy external gin-dex
+// service; callers should not need to care which.
+type Searcher interface {
+	// IndexRepository (re)indexes every blob in the tree at the head of
+	// repo's default branch and every commit on that branch's first-parent
+	// history, replacing any content indexed for it before.
+	IndexRepository(repo RepoRef) error
+
+	// IndexBlob incrementally adds a single blob to the index for repo.
+	// It is used on push hooks, where re-indexing the whole repository
+	// would be wasteful.
+	IndexBlob(repo RepoRef, blob *gig.Blob, path string, commitOid libgin.ObjectID) error
+
+	// RemoveBlob tombstones a blob that no longer exists at oid in repo,
+	// e.g. because it was deleted or renamed on push.
+	RemoveBlob(repo RepoRef, oid libgin.ObjectID) error
+
+	// IndexCommit incrementally adds a single commit's metadata (message,
+	// author, etc.) to the index for repo, so commit search finds it the
+	// same way IndexBlob makes a file's content searchable.
+	IndexCommit(repo RepoRef, commit *gig.Commit) error
+
+	// IndexPush incrementally indexes exactly the blobs and commits that
+	// changed between oldCommit and newCommit: this is the push-hook path
+	// and should be used instead of IndexRepository whenever a previous
+	// commit is known, so a push doesn't pay for a full repository
+	// re-walk. An empty oldCommit (first push to a new repository) is
+	// equivalent to IndexRepository.
+	IndexPush(repo RepoRef, oldCommit, newCommit string) error
+
+	// Search runs a query in one of the libgin.SEARCH_* modes and returns
+	// matching blobs and commits. Results are not ranked: the embedded
+	// index gives every hit the same score and returns hits in no
+	// particular order.
+	Search(query string, mode int64) (*libgin.SearchResults, error)
+}
+
+// Backend names accepted by setting.Search.Backend.
+const (
+	BackendEmbedded = "embedded"
+	BackendExternal = "external"
+)
+
+// Get returns the Searcher configured via setting.Search.Backend. It
+// defaults to the embedded index when the setting is empty, since that is
+// the only backend that requires no additional infrastructure.
+func Get() (Searcher, error) {
+	switch setting.Search.Backend {
+	case "", BackendEmbedded:
+		return defaultIndex, nil
+	case BackendExternal:
+		return newExternalSearcher(), nil
+	default:
+		return nil, fmt.Errorf("search: unknown backend %q", setting.Search.Backend)
+	}
+}
@@ -0,0 +1,74 @@
+package search
+
+import "sort"
+
+// suggestIndex answers prefix queries for SEARCH_SUGGEST. It is a small,
+// sorted-table stand-in for a full FST: a real FST would share suffixes
+// too, but for the handful of distinct paths in a single repository a
+// binary search over a sorted slice gives the same O(log n) prefix
+// lookup at a fraction of the implementation cost.
+type suggestIndex struct {
+	entries []suggestEntry
+}
+
+type suggestEntry struct {
+	key   string // lower-cased path, for prefix comparison
+	docID uint32
+}
+
+func buildSuggestIndex(docs []docRecord) *suggestIndex {
+	entries := make([]suggestEntry, 0, len(docs))
+	for docID, doc := range docs {
+		if !doc.Live {
+			continue
+		}
+		entries = append(entries, suggestEntry{key: lower(doc.Path), docID: uint32(docID)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return &suggestIndex{entries: entries}
+}
+
+func lower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+	return string(b)
+}
+
+// prefixDocIDs returns the docIDs of every live document whose path
+// starts with prefix, capped at limit results.
+func (s *suggestIndex) prefixDocIDs(prefix string, limit int) map[uint32]struct{} {
+	prefix = lower(prefix)
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].key >= prefix })
+
+	out := make(map[uint32]struct{})
+	for ; i < len(s.entries) && len(out) < limit; i++ {
+		if len(s.entries[i].key) < len(prefix) || s.entries[i].key[:len(prefix)] != prefix {
+			break
+		}
+		out[s.entries[i].docID] = struct{}{}
+	}
+	return out
+}
+
+// maxSuggestions bounds how many completions a single suggest query can
+// return, keeping the admin/search UI response snappy.
+const maxSuggestions = 20
+
+func (idx *repoIndex) rebuildSuggest() {
+	idx.suggest = buildSuggestIndex(idx.docs)
+}
+
+func (idx *repoIndex) suggestDocIDs(prefix string) map[uint32]struct{} {
+	idx.mu.Lock()
+	if idx.suggest == nil {
+		idx.rebuildSuggest()
+	}
+	suggest := idx.suggest
+	idx.mu.Unlock()
+
+	return suggest.prefixDocIDs(prefix, maxSuggestions)
+}
@@ -0,0 +1,778 @@
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/G-Node/gig"
+	"github.com/G-Node/gogs/internal/setting"
+	"github.com/G-Node/libgin/libgin"
+	log "gopkg.in/clog.v1"
+)
+
+// defaultIndex is the process-wide embedded trigram index, lazily loading
+// and persisting one file per repository under setting.Search.IndexPath.
+var defaultIndex = newTrigramIndex()
+
+// trigram is a case-folded 3-byte n-gram packed into the low 24 bits of a
+// uint32, used as a posting-list key.
+type trigram uint32
+
+func packTrigram(b0, b1, b2 byte) trigram {
+	return trigram(b0)<<16 | trigram(b1)<<8 | trigram(b2)
+}
+
+// trigramsOf returns the set of distinct trigrams in the case-folded
+// content. Content shorter than three bytes has no trigrams.
+func trigramsOf(content []byte) map[trigram]struct{} {
+	folded := bytes.ToLower(content)
+	out := make(map[trigram]struct{})
+	for i := 0; i+2 < len(folded); i++ {
+		out[packTrigram(folded[i], folded[i+1], folded[i+2])] = struct{}{}
+	}
+	return out
+}
+
+// posting is a single occurrence of a trigram in a document.
+type posting struct {
+	DocID  uint32
+	Offset uint32
+}
+
+// docKind distinguishes a blob document (file content at some commit)
+// from a commit document (that commit's own message) in the shared
+// postings table, so a single query candidate-set can contain both. The
+// zero value is docKindBlob, so index files written before commits were
+// indexed decode unchanged.
+type docKind uint8
+
+const (
+	docKindBlob docKind = iota
+	docKindCommit
+)
+
+// docRecord is the side table entry a docID resolves to. Oid and Commit
+// are libgin.ObjectID rather than gig.SHA1 so a document indexed from a
+// SHA-256 repository round-trips through save/load without truncation.
+// For a commit document (Kind == docKindCommit), Oid and Commit are the
+// same value: the commit's own ID.
+type docRecord struct {
+	Repo   RepoRef
+	Kind   docKind
+	Path   string
+	Oid    libgin.ObjectID
+	Commit libgin.ObjectID
+	Live   bool
+}
+
+// repoIndex holds the trigram posting lists and doc table for a single
+// repository, persisted as one gob-encoded file on disk.
+type repoIndex struct {
+	mu       sync.RWMutex
+	path     string
+	docs     []docRecord
+	postings map[trigram][]posting
+	suggest  *suggestIndex
+}
+
+// onDisk is the serialised form of a repoIndex.
+type onDisk struct {
+	Docs     []docRecord
+	Postings map[trigram][]posting
+}
+
+type trigramIndex struct {
+	mu             sync.Mutex
+	repos          map[int64]*repoIndex
+	loadedFromDisk bool
+}
+
+func newTrigramIndex() *trigramIndex {
+	return &trigramIndex{repos: make(map[int64]*repoIndex)}
+}
+
+func (ti *trigramIndex) indexPath(repoID int64) string {
+	return filepath.Join(setting.Search.IndexPath, fmt.Sprintf("%d.idx", repoID))
+}
+
+// repoIndexFor returns the index for repo, loading it from disk on first
+// use, or an empty in-memory one if no index file exists yet. Nothing is
+// written to disk here; callers save after adding to it.
+func (ti *trigramIndex) repoIndexFor(repo RepoRef) (*repoIndex, error) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	if idx, ok := ti.repos[repo.ID]; ok {
+		return idx, nil
+	}
+
+	idx := &repoIndex{
+		path:     ti.indexPath(repo.ID),
+		postings: make(map[trigram][]posting),
+	}
+	if err := idx.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("load index for repo %d: %v", repo.ID, err)
+	}
+	ti.repos[repo.ID] = idx
+	return idx, nil
+}
+
+// ensureAllLoaded loads every *.idx file under setting.Search.IndexPath
+// that isn't already in memory. Indexing only ever populates ti.repos for
+// the repository it just touched, so without this a freshly restarted
+// process has an empty ti.repos and Search silently returns nothing for
+// every repository until each one is reindexed again. It is idempotent
+// and a no-op after the first successful call.
+func (ti *trigramIndex) ensureAllLoaded() error {
+	ti.mu.Lock()
+	if ti.loadedFromDisk {
+		ti.mu.Unlock()
+		return nil
+	}
+	ti.mu.Unlock()
+
+	entries, err := os.ReadDir(setting.Search.IndexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			ti.mu.Lock()
+			ti.loadedFromDisk = true
+			ti.mu.Unlock()
+			return nil
+		}
+		return fmt.Errorf("list search index directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		repoID, ok := repoIDFromIndexFilename(entry.Name())
+		if !ok {
+			continue
+		}
+
+		ti.mu.Lock()
+		_, loaded := ti.repos[repoID]
+		ti.mu.Unlock()
+		if loaded {
+			continue
+		}
+
+		idx := &repoIndex{
+			path:     ti.indexPath(repoID),
+			postings: make(map[trigram][]posting),
+		}
+		if err := idx.load(); err != nil {
+			log.Error(2, "search: could not load index file %s: %v", entry.Name(), err)
+			continue
+		}
+
+		ti.mu.Lock()
+		if _, loaded := ti.repos[repoID]; !loaded {
+			ti.repos[repoID] = idx
+		}
+		ti.mu.Unlock()
+	}
+
+	ti.mu.Lock()
+	ti.loadedFromDisk = true
+	ti.mu.Unlock()
+	return nil
+}
+
+// repoIDFromIndexFilename extracts the repository ID from an index
+// filename produced by trigramIndex.indexPath ("<id>.idx").
+func repoIDFromIndexFilename(name string) (int64, bool) {
+	if !strings.HasSuffix(name, ".idx") {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(name, ".idx"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+func (idx *repoIndex) load() error {
+	f, err := os.Open(idx.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var onDisk onDisk
+	if err := gob.NewDecoder(f).Decode(&onDisk); err != nil {
+		return fmt.Errorf("decode: %v", err)
+	}
+	idx.docs = onDisk.Docs
+	idx.postings = onDisk.Postings
+	idx.rebuildSuggest()
+	return nil
+}
+
+// save persists the index atomically by writing to a temp file first, so
+// a crash mid-write never leaves a corrupt index behind.
+func (idx *repoIndex) save() error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return err
+	}
+	tmp := idx.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	onDisk := onDisk{Docs: idx.docs, Postings: idx.postings}
+	if err := gob.NewEncoder(f).Encode(&onDisk); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.path)
+}
+
+// addBlob indexes a single blob's content and (repo, path, oid, commit)
+// metadata, allocating a new docID. Callers must call save afterwards.
+func (idx *repoIndex) addBlob(repo RepoRef, blob *gig.Blob, path string, commit libgin.ObjectID) error {
+	content, err := blob.Contents()
+	if err != nil {
+		return fmt.Errorf("read blob %s: %v", blob.ID(), err)
+	}
+	oid, err := libgin.ObjectIDFromHex(string(blob.ID()))
+	if err != nil {
+		return fmt.Errorf("blob %s: %v", blob.ID(), err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	docID := uint32(len(idx.docs))
+	idx.docs = append(idx.docs, docRecord{
+		Repo:   repo,
+		Kind:   docKindBlob,
+		Path:   path,
+		Oid:    oid,
+		Commit: commit,
+		Live:   true,
+	})
+
+	idx.indexContent(docID, content)
+	idx.suggest = nil // rebuilt lazily on next suggest query
+	return nil
+}
+
+// addCommit indexes a single commit's message, so commit search finds
+// hits the same way blob search finds hits in file content. Callers must
+// call save afterwards.
+func (idx *repoIndex) addCommit(repo RepoRef, commit *gig.Commit) error {
+	oid, err := libgin.ObjectIDFromHex(string(commit.ID()))
+	if err != nil {
+		return fmt.Errorf("commit %s: %v", commit.ID(), err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	docID := uint32(len(idx.docs))
+	idx.docs = append(idx.docs, docRecord{
+		Repo:   repo,
+		Kind:   docKindCommit,
+		Oid:    oid,
+		Commit: oid,
+		Live:   true,
+	})
+
+	idx.indexContent(docID, []byte(commit.Message()))
+	idx.suggest = nil
+	return nil
+}
+
+// indexContent trigram-indexes content under docID. Callers must already
+// hold idx.mu.
+func (idx *repoIndex) indexContent(docID uint32, content []byte) {
+	folded := bytes.ToLower(content)
+	for i := 0; i+2 < len(folded); i++ {
+		tg := packTrigram(folded[i], folded[i+1], folded[i+2])
+		idx.postings[tg] = append(idx.postings[tg], posting{DocID: docID, Offset: uint32(i)})
+	}
+}
+
+// removeBlob tombstones every live doc matching oid, so it stops showing
+// up in results without requiring a full posting-list rewrite.
+func (idx *repoIndex) removeBlob(oid libgin.ObjectID) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i := range idx.docs {
+		if idx.docs[i].Oid == oid && idx.docs[i].Live {
+			idx.docs[i].Live = false
+		}
+	}
+	idx.suggest = nil
+	return nil
+}
+
+// candidates ANDs the posting lists of every trigram in tgs and returns
+// the set of docIDs containing all of them, restricted to live docs.
+func (idx *repoIndex) candidates(tgs map[trigram]struct{}) map[uint32]struct{} {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var sets [][]posting
+	for tg := range tgs {
+		sets = append(sets, idx.postings[tg])
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	present := make(map[uint32]struct{})
+	for _, p := range sets[0] {
+		present[p.DocID] = struct{}{}
+	}
+	for _, set := range sets[1:] {
+		next := make(map[uint32]struct{})
+		for _, p := range set {
+			if _, ok := present[p.DocID]; ok {
+				next[p.DocID] = struct{}{}
+			}
+		}
+		present = next
+	}
+
+	out := make(map[uint32]struct{})
+	for docID := range present {
+		if int(docID) < len(idx.docs) && idx.docs[docID].Live {
+			out[docID] = struct{}{}
+		}
+	}
+	return out
+}
+
+// unionCandidates ORs the posting lists of every trigram in tgs.
+func (idx *repoIndex) unionCandidates(tgs map[trigram]struct{}) map[uint32]struct{} {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	out := make(map[uint32]struct{})
+	for tg := range tgs {
+		for _, p := range idx.postings[tg] {
+			if int(p.DocID) < len(idx.docs) && idx.docs[p.DocID].Live {
+				out[p.DocID] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+func (idx *repoIndex) doc(docID uint32) docRecord {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.docs[docID]
+}
+
+// IndexRepository re-walks every blob reachable from repo's default
+// branch and rebuilds its index from scratch, mirroring the behaviour
+// the old external RebuildIndex relied on.
+func (ti *trigramIndex) IndexRepository(repo RepoRef) error {
+	g, err := gig.OpenRepository(repo.FullName)
+	if err != nil {
+		return fmt.Errorf("open repository %s: %v", repo.FullName, err)
+	}
+	head, err := g.GetBranchCommit(g.DefaultBranch())
+	if err != nil {
+		return fmt.Errorf("get HEAD commit for %s: %v", repo.FullName, err)
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return fmt.Errorf("get tree for %s: %v", repo.FullName, err)
+	}
+
+	headOid, err := libgin.ObjectIDFromHex(string(head.ID()))
+	if err != nil {
+		return fmt.Errorf("HEAD commit %s: %v", head.ID(), err)
+	}
+
+	idx := &repoIndex{
+		path:     ti.indexPath(repo.ID),
+		postings: make(map[trigram][]posting),
+	}
+	if err := tree.WalkBlobs(func(path string, blob *gig.Blob) error {
+		return idx.addBlob(repo, blob, path, headOid)
+	}); err != nil {
+		return fmt.Errorf("walk blobs for %s: %v", repo.FullName, err)
+	}
+
+	if err := walkCommits(head, func(c *gig.Commit) error {
+		return idx.addCommit(repo, c)
+	}); err != nil {
+		return fmt.Errorf("walk commits for %s: %v", repo.FullName, err)
+	}
+
+	if err := idx.save(); err != nil {
+		return fmt.Errorf("save index for %s: %v", repo.FullName, err)
+	}
+
+	ti.mu.Lock()
+	ti.repos[repo.ID] = idx
+	ti.mu.Unlock()
+	log.Trace("Indexed %d documents for repository %s", len(idx.docs), repo.FullName)
+	return nil
+}
+
+func (ti *trigramIndex) IndexBlob(repo RepoRef, blob *gig.Blob, path string, commit libgin.ObjectID) error {
+	idx, err := ti.repoIndexFor(repo)
+	if err != nil {
+		return err
+	}
+	if err := idx.addBlob(repo, blob, path, commit); err != nil {
+		return err
+	}
+	return idx.save()
+}
+
+func (ti *trigramIndex) RemoveBlob(repo RepoRef, oid libgin.ObjectID) error {
+	idx, err := ti.repoIndexFor(repo)
+	if err != nil {
+		return err
+	}
+	if err := idx.removeBlob(oid); err != nil {
+		return err
+	}
+	return idx.save()
+}
+
+func (ti *trigramIndex) IndexCommit(repo RepoRef, commit *gig.Commit) error {
+	idx, err := ti.repoIndexFor(repo)
+	if err != nil {
+		return err
+	}
+	if err := idx.addCommit(repo, commit); err != nil {
+		return err
+	}
+	return idx.save()
+}
+
+// walkCommits invokes fn for head and every ancestor reachable from it by
+// following first parents, mirroring how `git log --first-parent` walks
+// history. Commits reachable only through a merge commit's second or
+// later parents are not visited.
+func walkCommits(head *gig.Commit, fn func(*gig.Commit) error) error {
+	for c := head; c != nil; {
+		if err := fn(c); err != nil {
+			return err
+		}
+		if c.ParentsCount() == 0 {
+			return nil
+		}
+		parent, err := c.Parent(0)
+		if err != nil {
+			return fmt.Errorf("commit %s: get parent: %v", c.ID(), err)
+		}
+		c = parent
+	}
+	return nil
+}
+
+// walkCommitsBetween invokes fn for newCommit and every first-parent
+// ancestor up to but not including oldCommitID, which IndexPush's caller
+// guarantees was already indexed by a previous push or IndexRepository.
+func walkCommitsBetween(newCommit *gig.Commit, oldCommitID string, fn func(*gig.Commit) error) error {
+	for c := newCommit; c != nil; {
+		if string(c.ID()) == oldCommitID {
+			return nil
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+		if c.ParentsCount() == 0 {
+			return nil
+		}
+		parent, err := c.Parent(0)
+		if err != nil {
+			return fmt.Errorf("commit %s: get parent: %v", c.ID(), err)
+		}
+		c = parent
+	}
+	return nil
+}
+
+// IndexPush indexes only what changed between oldCommit and newCommit,
+// instead of re-walking the whole tree like IndexRepository: every blob
+// whose oid changed at a given path is (re-)added, and every path that
+// disappeared is tombstoned. oldCommit == "" means this is the
+// repository's first push, so there is nothing to diff against and a
+// full IndexRepository is used instead.
+func (ti *trigramIndex) IndexPush(repo RepoRef, oldCommit, newCommit string) error {
+	if oldCommit == "" {
+		return ti.IndexRepository(repo)
+	}
+
+	g, err := gig.OpenRepository(repo.FullName)
+	if err != nil {
+		return fmt.Errorf("open repository %s: %v", repo.FullName, err)
+	}
+
+	oldBlobs, err := blobsAtCommit(g, oldCommit)
+	if err != nil {
+		return fmt.Errorf("walk old commit %s for %s: %v", oldCommit, repo.FullName, err)
+	}
+
+	newCommitObj, err := g.GetCommit(newCommit)
+	if err != nil {
+		return fmt.Errorf("get commit %s for %s: %v", newCommit, repo.FullName, err)
+	}
+	newTree, err := newCommitObj.Tree()
+	if err != nil {
+		return fmt.Errorf("get tree for commit %s: %v", newCommit, err)
+	}
+	newCommitOid, err := libgin.ObjectIDFromHex(newCommit)
+	if err != nil {
+		return fmt.Errorf("new commit %s: %v", newCommit, err)
+	}
+
+	idx, err := ti.repoIndexFor(repo)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(oldBlobs))
+	if err := newTree.WalkBlobs(func(path string, blob *gig.Blob) error {
+		seen[path] = true
+		if oldOid, existed := oldBlobs[path]; existed && oldOid == string(blob.ID()) {
+			return nil
+		}
+		return idx.addBlob(repo, blob, path, newCommitOid)
+	}); err != nil {
+		return fmt.Errorf("walk new commit %s for %s: %v", newCommit, repo.FullName, err)
+	}
+
+	for path, oldOidHex := range oldBlobs {
+		if seen[path] {
+			continue
+		}
+		oldOid, err := libgin.ObjectIDFromHex(oldOidHex)
+		if err != nil {
+			log.Error(2, "search: skipping tombstone for %s in %s: %v", path, repo.FullName, err)
+			continue
+		}
+		if err := idx.removeBlob(oldOid); err != nil {
+			return fmt.Errorf("remove blob %s in %s: %v", path, repo.FullName, err)
+		}
+	}
+
+	if err := walkCommitsBetween(newCommitObj, oldCommit, func(c *gig.Commit) error {
+		return idx.addCommit(repo, c)
+	}); err != nil {
+		return fmt.Errorf("walk commits %s..%s for %s: %v", oldCommit, newCommit, repo.FullName, err)
+	}
+
+	if err := idx.save(); err != nil {
+		return fmt.Errorf("save index for %s: %v", repo.FullName, err)
+	}
+	log.Trace("Incrementally indexed push %s..%s for repository %s", oldCommit, newCommit, repo.FullName)
+	return nil
+}
+
+// blobsAtCommit returns path -> blob OID (hex) for every blob reachable
+// from commitID, so IndexPush can diff two trees without a full reindex.
+func blobsAtCommit(g *gig.Repository, commitID string) (map[string]string, error) {
+	commit, err := g.GetCommit(commitID)
+	if err != nil {
+		return nil, fmt.Errorf("get commit %s: %v", commitID, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("get tree for commit %s: %v", commitID, err)
+	}
+	out := make(map[string]string)
+	err = tree.WalkBlobs(func(path string, blob *gig.Blob) error {
+		out[path] = string(blob.ID())
+		return nil
+	})
+	return out, err
+}
+
+func (ti *trigramIndex) Search(query string, mode int64) (*libgin.SearchResults, error) {
+	if err := ti.ensureAllLoaded(); err != nil {
+		return nil, err
+	}
+
+	ti.mu.Lock()
+	repos := make([]*repoIndex, 0, len(ti.repos))
+	for _, idx := range ti.repos {
+		repos = append(repos, idx)
+	}
+	ti.mu.Unlock()
+
+	results := &libgin.SearchResults{}
+	for _, idx := range repos {
+		docIDs, err := idx.matchDocIDs(query, mode)
+		if err != nil {
+			return nil, err
+		}
+		for docID := range docIDs {
+			doc := idx.doc(docID)
+			switch doc.Kind {
+			case docKindCommit:
+				results.Commits = append(results.Commits, libgin.CommitSResult{
+					Source: &libgin.IndexCommit{
+						GinRepoId:   fmt.Sprintf("%d", doc.Repo.ID),
+						GinRepoName: doc.Repo.FullName,
+						Oid:         doc.Oid,
+					},
+					Score: 1.0,
+				})
+			default:
+				results.Blobs = append(results.Blobs, libgin.BlobSResult{
+					Source: &libgin.IndexBlob{
+						GinRepoId:   fmt.Sprintf("%d", doc.Repo.ID),
+						GinRepoName: doc.Repo.FullName,
+						Oid:         doc.Oid,
+						FirstCommit: doc.Commit.String(),
+						Path:        doc.Path,
+					},
+					Score: 1.0,
+				})
+			}
+		}
+	}
+	return results, nil
+}
+
+// matchDocIDs dispatches to the strategy for the given libgin.SEARCH_*
+// mode. Except in SEARCH_SUGGEST, which matches on paths alone, every
+// candidate is verified against the actual blob content or commit
+// message before it is returned, since a document can contain every
+// trigram of a query without containing the query itself.
+func (idx *repoIndex) matchDocIDs(query string, mode int64) (map[uint32]struct{}, error) {
+	switch mode {
+	case libgin.SEARCH_MATCH:
+		return idx.verify(idx.candidates(trigramsOf([]byte(query))), func(content []byte) bool {
+			return bytes.Contains(bytes.ToLower(content), bytes.ToLower([]byte(query)))
+		})
+
+	case libgin.SEARCH_FUZZY:
+		// Fuzzy search widens the candidate set to anything sharing at
+		// least one trigram with the query, then verifies with an
+		// edit-distance threshold instead of an exact substring check.
+		return idx.verify(idx.unionCandidates(trigramsOf([]byte(query))), func(content []byte) bool {
+			return containsWithinEditDistance(string(content), query, fuzzyMaxDistance(query))
+		})
+
+	case libgin.SEARCH_WILDCARD:
+		pattern, err := wildcardToRegexp(query)
+		if err != nil {
+			return nil, err
+		}
+		candidates := idx.wildcardCandidates(query)
+		return idx.verify(candidates, func(content []byte) bool {
+			return pattern.Match(content)
+		})
+
+	case libgin.SEARCH_QUERRY:
+		words := strings.Fields(query)
+		out := make(map[uint32]struct{})
+		for _, w := range words {
+			if len(w) < 3 {
+				continue
+			}
+			for docID := range idx.unionCandidates(trigramsOf([]byte(w))) {
+				out[docID] = struct{}{}
+			}
+		}
+		return idx.verify(out, func(content []byte) bool {
+			lower := bytes.ToLower(content)
+			for _, w := range words {
+				if bytes.Contains(lower, bytes.ToLower([]byte(w))) {
+					return true
+				}
+			}
+			return false
+		})
+
+	case libgin.SEARCH_SUGGEST:
+		return idx.suggestDocIDs(query), nil
+
+	default:
+		return nil, fmt.Errorf("search: unknown mode %d", mode)
+	}
+}
+
+// wildcardCandidates ANDs the trigram sets of each literal segment split
+// on '*', since a matching document must contain every segment
+// somewhere, even though the wildcard means they need not be adjacent.
+func (idx *repoIndex) wildcardCandidates(pattern string) map[uint32]struct{} {
+	segments := strings.Split(pattern, "*")
+	sets := make(map[trigram]struct{})
+	for _, seg := range segments {
+		if len(seg) < 3 {
+			continue
+		}
+		for tg := range trigramsOf([]byte(seg)) {
+			sets[tg] = struct{}{}
+		}
+	}
+	if len(sets) == 0 {
+		// Every segment is too short to filter on; fall back to a full
+		// scan of all live documents.
+		idx.mu.RLock()
+		defer idx.mu.RUnlock()
+		out := make(map[uint32]struct{})
+		for docID, doc := range idx.docs {
+			if doc.Live {
+				out[uint32(docID)] = struct{}{}
+			}
+		}
+		return out
+	}
+	return idx.candidates(sets)
+}
+
+func wildcardToRegexp(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.Compile("(?i)" + strings.Join(parts, ".*"))
+}
+
+func (idx *repoIndex) verify(candidates map[uint32]struct{}, keep func(content []byte) bool) (map[uint32]struct{}, error) {
+	out := make(map[uint32]struct{})
+	for docID := range candidates {
+		doc := idx.doc(docID)
+		content, err := docContent(doc)
+		if err != nil {
+			log.Error(2, "search: could not load %s in %s for verification: %v", doc.Oid, doc.Repo.FullName, err)
+			continue
+		}
+		if keep(content) {
+			out[docID] = struct{}{}
+		}
+	}
+	return out, nil
+}
+
+// docContent fetches the content a doc's trigrams were extracted from: a
+// blob's file content, or a commit's message.
+func docContent(doc docRecord) ([]byte, error) {
+	if doc.Kind == docKindCommit {
+		commit, err := gig.GetCommit(doc.Repo.FullName, doc.Oid.String())
+		if err != nil {
+			return nil, err
+		}
+		return []byte(commit.Message()), nil
+	}
+	blob, err := gig.GetBlob(doc.Repo.FullName, doc.Oid.String())
+	if err != nil {
+		return nil, err
+	}
+	return blob.Contents()
+}
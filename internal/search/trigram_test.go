@@ -0,0 +1,158 @@
+package search
+
+import (
+	"testing"
+)
+
+func newTestIndex(docs []docRecord) *repoIndex {
+	idx := &repoIndex{docs: docs, postings: make(map[trigram][]posting)}
+	for docID, doc := range docs {
+		if !doc.Live {
+			continue
+		}
+		// content isn't stored on disk for this test; derive trigrams
+		// straight from the path so candidates/unionCandidates have
+		// something to match against.
+		for tg := range trigramsOf([]byte(doc.Path)) {
+			idx.postings[tg] = append(idx.postings[tg], posting{DocID: uint32(docID)})
+		}
+	}
+	return idx
+}
+
+func TestCandidatesIntersectsAllTrigrams(t *testing.T) {
+	idx := newTestIndex([]docRecord{
+		{Path: "foo.go", Live: true},
+		{Path: "bar.go", Live: true},
+		{Path: "foobar.go", Live: true},
+	})
+
+	got := idx.candidates(trigramsOf([]byte("foobar")))
+	if _, ok := got[2]; !ok || len(got) != 1 {
+		t.Fatalf("candidates(%q) = %v, want only doc 2", "foobar", got)
+	}
+}
+
+func TestCandidatesExcludesTombstonedDocs(t *testing.T) {
+	idx := newTestIndex([]docRecord{
+		{Path: "foobar.go", Live: false},
+	})
+
+	if got := idx.candidates(trigramsOf([]byte("foobar"))); len(got) != 0 {
+		t.Fatalf("candidates() = %v, want empty for a dead doc", got)
+	}
+}
+
+func TestCandidatesMatchCommitDocsAlongsideBlobDocs(t *testing.T) {
+	idx := newTestIndex([]docRecord{
+		{Kind: docKindBlob, Path: "fix-flaky-test.go", Live: true},
+		{Kind: docKindCommit, Path: "fix flaky test", Live: true},
+		{Kind: docKindBlob, Path: "unrelated.go", Live: true},
+	})
+
+	got := idx.candidates(trigramsOf([]byte("flaky")))
+	if len(got) != 2 {
+		t.Fatalf("candidates(%q) = %v, want the blob doc and the commit doc", "flaky", got)
+	}
+	if _, ok := got[1]; !ok {
+		t.Fatalf("candidates(%q) missed the commit doc", "flaky")
+	}
+}
+
+func TestUnionCandidatesIsAnOr(t *testing.T) {
+	idx := newTestIndex([]docRecord{
+		{Path: "foo.go", Live: true},
+		{Path: "bar.go", Live: true},
+		{Path: "baz.go", Live: true},
+	})
+
+	got := idx.unionCandidates(trigramsOf([]byte("foo")))
+	got2 := idx.unionCandidates(trigramsOf([]byte("bar")))
+	for docID := range got2 {
+		got[docID] = struct{}{}
+	}
+	if len(got) != 2 {
+		t.Fatalf("union of foo/bar candidates = %v, want 2 docs", got)
+	}
+	if _, ok := got[2]; ok {
+		t.Fatalf("union of foo/bar candidates unexpectedly matched baz.go")
+	}
+}
+
+func TestWildcardToRegexp(t *testing.T) {
+	re, err := wildcardToRegexp("foo*.go")
+	if err != nil {
+		t.Fatalf("wildcardToRegexp returned error: %v", err)
+	}
+	for _, tc := range []struct {
+		in   string
+		want bool
+	}{
+		{"foo.go", true},
+		{"foobar.go", true},
+		{"FOOBAR.GO", true}, // matching is case-insensitive
+		{"bar.go", false},
+	} {
+		if got := re.MatchString(tc.in); got != tc.want {
+			t.Errorf("wildcardToRegexp(%q).MatchString(%q) = %v, want %v", "foo*.go", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestWildcardToRegexpEscapesLiteralSegments(t *testing.T) {
+	re, err := wildcardToRegexp("a.b*c")
+	if err != nil {
+		t.Fatalf("wildcardToRegexp returned error: %v", err)
+	}
+	if re.MatchString("aXb.c") {
+		t.Fatalf("wildcardToRegexp(%q) should treat '.' as literal, matched %q", "a.b*c", "aXb.c")
+	}
+	if !re.MatchString("a.bZZZc") {
+		t.Fatalf("wildcardToRegexp(%q) should match %q", "a.b*c", "a.bZZZc")
+	}
+}
+
+func TestRepoIDFromIndexFilename(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		wantID int64
+		wantOK bool
+	}{
+		{"42.idx", 42, true},
+		{"not-an-idx.txt", 0, false},
+		{"abc.idx", 0, false},
+	} {
+		id, ok := repoIDFromIndexFilename(tc.name)
+		if id != tc.wantID || ok != tc.wantOK {
+			t.Errorf("repoIDFromIndexFilename(%q) = (%d, %v), want (%d, %v)", tc.name, id, ok, tc.wantID, tc.wantOK)
+		}
+	}
+}
+
+func TestPrefixDocIDs(t *testing.T) {
+	idx := buildSuggestIndex([]docRecord{
+		{Path: "src/main.go", Live: true},
+		{Path: "src/helper.go", Live: true},
+		{Path: "docs/readme.md", Live: false},
+	})
+
+	got := idx.prefixDocIDs("src/", 10)
+	if len(got) != 2 {
+		t.Fatalf("prefixDocIDs(%q) = %v, want 2 live matches", "src/", got)
+	}
+	if _, ok := got[2]; ok {
+		t.Fatalf("prefixDocIDs(%q) unexpectedly included a dead doc", "src/")
+	}
+}
+
+func TestPrefixDocIDsRespectsLimit(t *testing.T) {
+	idx := buildSuggestIndex([]docRecord{
+		{Path: "a/1", Live: true},
+		{Path: "a/2", Live: true},
+		{Path: "a/3", Live: true},
+	})
+
+	if got := idx.prefixDocIDs("a/", 1); len(got) != 1 {
+		t.Fatalf("prefixDocIDs with limit 1 = %v, want exactly 1 result", got)
+	}
+}
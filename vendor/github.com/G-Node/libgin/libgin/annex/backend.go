@@ -0,0 +1,26 @@
+package annex
+
+import "os/exec"
+
+// SHA256 sets the git-annex default backend at path to SHA256, alongside
+// the long-standing MD5 default, for instances that want annex content
+// keys to track Git's own move to SHA-256 object IDs.
+func SHA256(path string) (string, error) {
+	return setBackend(path, "SHA256")
+}
+
+// SHA256E is SHA256, but with the annexed file's extension kept as part
+// of the content key (git-annex's "E" backend variants), so annexed
+// symlinks and pointer files stay recognisable by extension.
+func SHA256E(path string) (string, error) {
+	return setBackend(path, "SHA256E")
+}
+
+// setBackend runs `git annex config`, rather than editing .git/config
+// directly, so annex validates the backend name itself.
+func setBackend(path, backend string) (string, error) {
+	cmd := exec.Command("git", "annex", "config", "--set", "annex.backend", backend)
+	cmd.Dir = path
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
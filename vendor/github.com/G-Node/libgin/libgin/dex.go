@@ -47,7 +47,7 @@ type IndexBlob struct {
 	GinRepoId    string
 	FirstCommit  string
 	Id           int64
-	Oid          gig.SHA1
+	Oid          ObjectID
 	IndexingTime time.Time
 	Content      string
 	Path         string
@@ -56,7 +56,7 @@ type IndexBlob struct {
 type IndexCommit struct {
 	*gig.Commit
 	GinRepoId    string
-	Oid          gig.SHA1
+	Oid          ObjectID
 	GinRepoName  string
 	IndexingTime time.Time
 }
@@ -0,0 +1,59 @@
+package libgin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetectObjectFormat reads extensions.objectFormat out of a (bare)
+// repository's config file directly, rather than shelling out to `git
+// config`, since this is called on every fetch of a repo's identity and
+// a raw file read is cheap. Repositories created before Git gained
+// SHA-256 support have no such key and are assumed to be SHA-1, which is
+// still Git's default object format.
+func DetectObjectFormat(repoPath string) (ObjectFormat, error) {
+	f, err := os.Open(filepath.Join(repoPath, "config"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ObjectFormatSHA1, nil
+		}
+		return ObjectFormatSHA1, fmt.Errorf("open config: %v", err)
+	}
+	defer f.Close()
+
+	inExtensions := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "["):
+			inExtensions = strings.EqualFold(line, "[extensions]")
+		case inExtensions && strings.HasPrefix(line, "objectformat"):
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if strings.EqualFold(strings.TrimSpace(parts[1]), "sha256") {
+				return ObjectFormatSHA256, nil
+			}
+			return ObjectFormatSHA1, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ObjectFormatSHA1, fmt.Errorf("read config: %v", err)
+	}
+	return ObjectFormatSHA1, nil
+}
+
+// InitArgsForObjectFormat returns the extra `git init` arguments needed
+// to create a new repository in the given object format. SHA-1 needs
+// none, since it is still Git's built-in default.
+func InitArgsForObjectFormat(format ObjectFormat) []string {
+	if format == ObjectFormatSHA256 {
+		return []string{"--object-format=sha256"}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package libgin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestDetectObjectFormatDefaultsToSHA1WhenNoConfig(t *testing.T) {
+	dir := t.TempDir()
+	format, err := DetectObjectFormat(dir)
+	if err != nil {
+		t.Fatalf("DetectObjectFormat: %v", err)
+	}
+	if format != ObjectFormatSHA1 {
+		t.Fatalf("format = %v, want ObjectFormatSHA1 for a repo with no config file", format)
+	}
+}
+
+func TestDetectObjectFormatDefaultsToSHA1WhenNoExtensionsSection(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "[core]\n\tbare = true\n")
+
+	format, err := DetectObjectFormat(dir)
+	if err != nil {
+		t.Fatalf("DetectObjectFormat: %v", err)
+	}
+	if format != ObjectFormatSHA1 {
+		t.Fatalf("format = %v, want ObjectFormatSHA1 when no [extensions] section is present", format)
+	}
+}
+
+func TestDetectObjectFormatReadsSHA256(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "[core]\n\tbare = true\n[extensions]\n\tobjectformat = sha256\n")
+
+	format, err := DetectObjectFormat(dir)
+	if err != nil {
+		t.Fatalf("DetectObjectFormat: %v", err)
+	}
+	if format != ObjectFormatSHA256 {
+		t.Fatalf("format = %v, want ObjectFormatSHA256", format)
+	}
+}
+
+func TestDetectObjectFormatIsCaseInsensitive(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "[EXTENSIONS]\n\tobjectFormat = SHA256\n")
+
+	format, err := DetectObjectFormat(dir)
+	if err != nil {
+		t.Fatalf("DetectObjectFormat: %v", err)
+	}
+	if format != ObjectFormatSHA256 {
+		t.Fatalf("format = %v, want ObjectFormatSHA256 regardless of key/value case", format)
+	}
+}
+
+func TestInitArgsForObjectFormat(t *testing.T) {
+	if args := InitArgsForObjectFormat(ObjectFormatSHA1); args != nil {
+		t.Fatalf("InitArgsForObjectFormat(SHA1) = %v, want nil (SHA-1 is git's default)", args)
+	}
+	if args := InitArgsForObjectFormat(ObjectFormatSHA256); len(args) == 0 {
+		t.Fatalf("InitArgsForObjectFormat(SHA256) returned no arguments")
+	}
+}
@@ -0,0 +1,136 @@
+package libgin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/G-Node/gig"
+)
+
+// ObjectFormat identifies which hash algorithm an ObjectID holds. Git
+// itself calls this the repository's "object format"; SHA-1 is still the
+// default everywhere, but upstream Git has stabilised SHA-256 as an
+// opt-in alternative (extensions.objectFormat = sha256 in a repo's
+// config), so long-lived records like IndexBlob/IndexCommit need to be
+// able to hold either.
+type ObjectFormat int
+
+const (
+	ObjectFormatSHA1 ObjectFormat = iota
+	ObjectFormatSHA256
+)
+
+func (f ObjectFormat) String() string {
+	switch f {
+	case ObjectFormatSHA1:
+		return "sha1"
+	case ObjectFormatSHA256:
+		return "sha256"
+	default:
+		return "unknown"
+	}
+}
+
+// ObjectID is a git object ID in either the SHA-1 or the SHA-256 object
+// format, discriminated by Format. Callers that only ever deal with one
+// repository's format can ignore the union and just use String().
+type ObjectID struct {
+	Format ObjectFormat
+	SHA1   gig.SHA1
+	SHA256 [32]byte
+}
+
+// NewSHA1ObjectID wraps a gig.SHA1 as an ObjectID.
+func NewSHA1ObjectID(oid gig.SHA1) ObjectID {
+	return ObjectID{Format: ObjectFormatSHA1, SHA1: oid}
+}
+
+// NewSHA256ObjectID wraps a raw SHA-256 digest as an ObjectID.
+func NewSHA256ObjectID(oid [32]byte) ObjectID {
+	return ObjectID{Format: ObjectFormatSHA256, SHA256: oid}
+}
+
+// ObjectIDFromHex wraps a hex object ID returned by git/gig into an
+// ObjectID, picking the format from its length (40 hex chars for
+// SHA-1, 64 for SHA-256). This lets callers that only ever see hex
+// strings (e.g. gig, before it grows native SHA-256 support) build a
+// format-correct ObjectID without having to separately detect the
+// repository's object format.
+func ObjectIDFromHex(hexOid string) (ObjectID, error) {
+	raw, err := hex.DecodeString(hexOid)
+	if err != nil {
+		return ObjectID{}, fmt.Errorf("libgin: object ID %q is not valid hex: %v", hexOid, err)
+	}
+	switch len(raw) {
+	case 20:
+		return NewSHA1ObjectID(gig.SHA1(hexOid)), nil
+	case 32:
+		var digest [32]byte
+		copy(digest[:], raw)
+		return NewSHA256ObjectID(digest), nil
+	default:
+		return ObjectID{}, fmt.Errorf("libgin: object ID %q has unexpected length %d", hexOid, len(raw))
+	}
+}
+
+// String returns the lower-case hex encoding of the object ID, without
+// the "hash:" discriminator JSON uses.
+func (o ObjectID) String() string {
+	if o.Format == ObjectFormatSHA256 {
+		return hex.EncodeToString(o.SHA256[:])
+	}
+	return string(o.SHA1)
+}
+
+// MarshalJSON encodes the ObjectID as "hash:<format>:<hex>", e.g.
+// "hash:sha256:<64 hex chars>", so that any consumer still expecting a
+// bare SHA-1 hex string fails to parse instead of silently truncating or
+// misinterpreting a SHA-256 value.
+func (o ObjectID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("hash:%s:%s", o.Format, o.String()))
+}
+
+// UnmarshalJSON parses the "hash:<format>:<hex>" form produced by
+// MarshalJSON.
+func (o *ObjectID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	const prefix = "hash:"
+	if !strings.HasPrefix(s, prefix) {
+		return fmt.Errorf("libgin: object ID %q missing %q prefix", s, prefix)
+	}
+	rest := s[len(prefix):]
+	sep := strings.IndexByte(rest, ':')
+	if sep < 0 {
+		return fmt.Errorf("libgin: object ID %q missing format separator", s)
+	}
+	format, hexPart := rest[:sep], rest[sep+1:]
+
+	raw, err := hex.DecodeString(hexPart)
+	if err != nil {
+		return fmt.Errorf("libgin: object ID %q is not valid hex: %v", s, err)
+	}
+
+	switch format {
+	case ObjectFormatSHA1.String():
+		if len(raw) != 20 {
+			return fmt.Errorf("libgin: sha1 object ID %q has wrong length", s)
+		}
+		*o = NewSHA1ObjectID(gig.SHA1(hexPart))
+	case ObjectFormatSHA256.String():
+		if len(raw) != 32 {
+			return fmt.Errorf("libgin: sha256 object ID %q has wrong length", s)
+		}
+		var digest [32]byte
+		copy(digest[:], raw)
+		*o = NewSHA256ObjectID(digest)
+	default:
+		return fmt.Errorf("libgin: object ID %q has unknown format %q", s, format)
+	}
+	return nil
+}